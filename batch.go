@@ -0,0 +1,74 @@
+package scache
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// batcher collects the keys of the concurrent misses of Get during the window
+// and loads them by one call of the bulk loader function. The batch is loaded
+// before the end of the window if it has the max count of keys.
+type batcher struct {
+	cache   *Cache
+	window  time.Duration
+	maxSize int
+	mu      sync.Mutex
+	pending *batch
+}
+
+func newBatcher(cache *Cache, window time.Duration, maxSize int) *batcher {
+	return &batcher{
+		cache:   cache,
+		window:  window,
+		maxSize: maxSize,
+	}
+}
+
+func (b *batcher) load(ctx context.Context, bID int, key interface{}) (value interface{}, err error) {
+
+	g := b.cache.flights[bID]
+
+	b.mu.Lock()
+
+	if b.pending != nil && b.pending.ctx.Err() != nil {
+		// the waiters of all keys of the batch stopped waiting,
+		// the calls of the keys are finished with the error
+		b.cache.runBatch(b.pending)
+		b.pending = nil
+	}
+
+	if b.pending == nil {
+		pending := newBatch(ctx)
+		b.pending = pending
+		time.AfterFunc(b.window, func() {
+			b.flush(pending)
+		})
+	}
+
+	call := b.pending.join(g, bID, key)
+	if b.maxSize > 0 && len(b.pending.keys) >= b.maxSize {
+		b.cache.runBatch(b.pending)
+		b.pending = nil
+	}
+
+	b.mu.Unlock()
+
+	return loadedValue(g.wait(ctx, call))
+}
+
+// flush loads the batch at the end of the window if it isn't loaded yet.
+func (b *batcher) flush(pending *batch) {
+
+	b.mu.Lock()
+	if b.pending == pending {
+		b.pending = nil
+	} else {
+		pending = nil // the batch is full or cancelled, it's loaded already
+	}
+	b.mu.Unlock()
+
+	if pending != nil {
+		b.cache.runBatch(pending)
+	}
+}
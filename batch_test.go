@@ -0,0 +1,110 @@
+package scache
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newBatchTestLoader(calls *int32, sizes chan<- int) BulkLoadFunc {
+	return func(ctx context.Context, keys []interface{}) (values map[interface{}]interface{}, err error) {
+		atomic.AddInt32(calls, 1)
+		if sizes != nil {
+			sizes <- len(keys)
+		}
+
+		values = make(map[interface{}]interface{})
+		for _, key := range keys {
+			values[key] = key
+		}
+		return
+	}
+}
+
+func TestBatcherWindow(t *testing.T) {
+
+	var calls int32
+	sizes := make(chan int, 10)
+
+	cache, err := New(4, 100).LRU().
+		BulkLoaderFunc(newBatchTestLoader(&calls, sizes)).
+		BatchWindow(50 * time.Millisecond).
+		Build()
+	require.NoError(t, err)
+	defer cache.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(key string) {
+			defer wg.Done()
+			val, err := cache.Get(key)
+			require.NoError(t, err)
+			require.Equal(t, key, val)
+		}(strconv.Itoa(i % 5))
+	}
+	wg.Wait()
+
+	require.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	require.Equal(t, 5, <-sizes)
+	require.Equal(t, int64(5), cache.Count())
+}
+
+func TestBatcherMaxBatchSize(t *testing.T) {
+
+	var calls int32
+	sizes := make(chan int, 10)
+
+	cache, err := New(4, 100).LRU().
+		BulkLoaderFunc(newBatchTestLoader(&calls, sizes)).
+		BatchWindow(time.Hour).
+		MaxBatchSize(3).
+		Build()
+	require.NoError(t, err)
+	defer cache.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func(key string) {
+			defer wg.Done()
+			val, err := cache.Get(key)
+			require.NoError(t, err)
+			require.Equal(t, key, val)
+		}(strconv.Itoa(i))
+	}
+	wg.Wait()
+
+	require.Equal(t, int32(2), atomic.LoadInt32(&calls))
+	require.Equal(t, 3, <-sizes)
+	require.Equal(t, 3, <-sizes)
+}
+
+func TestBatcherCancel(t *testing.T) {
+
+	var calls int32
+
+	cache, err := New(4, 100).LRU().
+		BulkLoaderFunc(newBatchTestLoader(&calls, nil)).
+		BatchWindow(20 * time.Millisecond).
+		Build()
+	require.NoError(t, err)
+	defer cache.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+
+	_, err = cache.GetCtx(ctx, "key")
+	require.Equal(t, context.DeadlineExceeded, err)
+
+	// the new batch is created instead of the cancelled one
+	val, err := cache.Get("key")
+	require.NoError(t, err)
+	require.Equal(t, "key", val)
+	require.Equal(t, int32(1), atomic.LoadInt32(&calls))
+}
@@ -8,8 +8,9 @@ import (
 )
 
 type builder struct {
-	conf     *Config
-	loadFunc LoadFunc
+	conf         *Config
+	loadFunc     LoadFuncWithMeta
+	bulkLoadFunc BulkLoadFunc
 }
 
 func New(shards int, maxSize int64) *builder {
@@ -33,21 +34,186 @@ func (b *builder) LRU() *builder {
 	return b
 }
 
+// ExactLRU evicts items in the strict LRU order of the shard.
+func (b *builder) ExactLRU() *builder {
+	b.conf.Kind = KindExactLRU
+	return b
+}
+
+func (b *builder) LFU() *builder {
+	b.conf.Kind = KindLFU
+	return b
+}
+
+func (b *builder) TinyLFU() *builder {
+	b.conf.Kind = KindTinyLFU
+	return b
+}
+
+func (b *builder) ARC() *builder {
+	b.conf.Kind = KindARC
+	return b
+}
+
+func (b *builder) SIEVE() *builder {
+	b.conf.Kind = KindSIEVE
+	return b
+}
+
+func (b *builder) S3FIFO() *builder {
+	b.conf.Kind = KindS3FIFO
+	return b
+}
+
 func (b *builder) TTL(val time.Duration) *builder {
 	b.conf.TTL = val
 	return b
 }
 
 func (b *builder) LoaderFunc(val LoadFunc) *builder {
+
+	b.loadFunc = nil
+	if val != nil {
+		b.loadFunc = func(_ context.Context, key interface{}) (value interface{}, ttl time.Duration, cost int64, err error) {
+			value, err = val(key)
+			return
+		}
+	}
+
+	return b
+}
+
+// LoaderFuncCtx sets the loader function which gets the context of the caller.
+func (b *builder) LoaderFuncCtx(val LoadFuncCtx) *builder {
+
+	b.loadFunc = nil
+	if val != nil {
+		b.loadFunc = func(ctx context.Context, key interface{}) (value interface{}, ttl time.Duration, cost int64, err error) {
+			value, err = val(ctx, key)
+			return
+		}
+	}
+
+	return b
+}
+
+// LoaderFuncWithMeta sets the loader function which returns the lifetime
+// and the weight of the value.
+func (b *builder) LoaderFuncWithMeta(val LoadFuncWithMeta) *builder {
 	b.loadFunc = val
 	return b
 }
 
+// BulkLoaderFunc sets the loader function which loads the missing keys of
+// GetMulti by one call. It's used by Get if the loader function isn't set.
+func (b *builder) BulkLoaderFunc(val BulkLoadFunc) *builder {
+	b.bulkLoadFunc = val
+	return b
+}
+
+// BatchWindow sets the time during which the misses of Get are collected
+// to be loaded by one call of the bulk loader function.
+func (b *builder) BatchWindow(val time.Duration) *builder {
+	b.conf.BatchWindow = val
+	return b
+}
+
+// MaxBatchSize sets the max count of keys which are loaded by one call of the bulk loader function.
+func (b *builder) MaxBatchSize(val int) *builder {
+	b.conf.MaxBatchSize = val
+	return b
+}
+
+// RefreshAhead sets the part of the lifetime of the item, the item is reloaded
+// in the background by Get at the end of its lifetime, e.g. 0.2 is the last 20%.
+func (b *builder) RefreshAhead(val float64) *builder {
+	b.conf.RefreshAhead = val
+	return b
+}
+
+// StaleTTL sets the period after the end of the lifetime of the item during
+// which the stale item is returned while it's reloaded in the background.
+func (b *builder) StaleTTL(val time.Duration) *builder {
+	b.conf.StaleTTL = val
+	return b
+}
+
+// XFetch enables the probabilistic early expiration of the loaded items with
+// the beta parameter, 1 is the good default.
+func (b *builder) XFetch(beta float64) *builder {
+	b.conf.XFetchBeta = beta
+	return b
+}
+
+// SlidingExpiration moves the expiration time of the item forward
+// by its lifetime on every Get.
+func (b *builder) SlidingExpiration() *builder {
+	b.conf.SlidingExpiration = true
+	return b
+}
+
+// ExpirationInterval sets the tick of the timing wheel which deletes
+// the expired items in the background.
+func (b *builder) ExpirationInterval(val time.Duration) *builder {
+	b.conf.ExpirationInterval = val
+	return b
+}
+
+// OnEvict sets the function which is called in the background when the item
+// is removed from the cache, e.g. it closes the resources of the value.
+func (b *builder) OnEvict(val EvictFunc) *builder {
+	b.conf.OnEvict = val
+	return b
+}
+
+// EventBuffer sets the size of the buffer of the subscriber of events,
+// the events are dropped when the buffer is full.
+func (b *builder) EventBuffer(val int) *builder {
+	b.conf.EventBuffer = val
+	return b
+}
+
+// Clock sets the clock of the expiration of items, e.g. the fake clock in tests.
+func (b *builder) Clock(val Clock) *builder {
+	b.conf.Clock = val
+	return b
+}
+
 func (b *builder) ItemsToPrune(val uint32) *builder {
 	b.conf.ItemsToPrune = val
 	return b
 }
 
+// Weigher sets the function which returns the weight of the item,
+// the size of the cache is the limit of the total weight then.
+func (b *builder) Weigher(val WeighFunc) *builder {
+	b.conf.Weigher = val
+	return b
+}
+
+func (b *builder) EvictionSamples(val int) *builder {
+	b.conf.EvictionSamples = val
+	return b
+}
+
+// ErrorTTL sets the lifetime of the errors of the loader function.
+func (b *builder) ErrorTTL(val time.Duration) *builder {
+	b.conf.ErrorTTL = val
+	return b
+}
+
+// NotFoundTTL sets the lifetime of the ErrNotFound error of the loader function.
+func (b *builder) NotFoundTTL(val time.Duration) *builder {
+	b.conf.NotFoundTTL = val
+	return b
+}
+
+// CacheableError sets the function which selects the errors of the loader function to cache.
+func (b *builder) CacheableError(val func(err error) bool) *builder {
+	b.conf.CacheableError = val
+	return b
+}
+
 func (b *builder) Build() (*Cache, error) {
 
 	if b.conf.Shards <= 0 || b.conf.Shards >= math.MaxUint32 {
@@ -62,14 +228,79 @@ func (b *builder) Build() (*Cache, error) {
 		return nil, errors.New("invalid cache time to live")
 	}
 
+	switch b.conf.Kind {
+	case KindTinyLFU, KindARC, KindSIEVE, KindS3FIFO, KindExactLRU:
+		// every shard keeps one item at least
+		if int64(b.conf.Shards) > b.conf.MaxSize {
+			return nil, errors.New("count of shards exceeds size")
+		}
+	}
+
+	if b.conf.EvictionSamples < 0 {
+		return nil, errors.New("invalid count of eviction samples")
+	}
+
+	if b.conf.ErrorTTL < 0 || b.conf.NotFoundTTL < 0 {
+		return nil, errors.New("invalid time to live of errors")
+	}
+
+	if b.conf.BatchWindow < 0 || b.conf.MaxBatchSize < 0 {
+		return nil, errors.New("invalid batch window")
+	}
+
+	if b.conf.BatchWindow > 0 && b.bulkLoadFunc == nil {
+		return nil, errors.New("batch window requires bulk loader function")
+	}
+
+	if b.conf.RefreshAhead < 0 || b.conf.RefreshAhead >= 1 {
+		return nil, errors.New("invalid refresh ahead fraction")
+	}
+
+	if b.conf.RefreshAhead > 0 && b.loadFunc == nil {
+		return nil, errors.New("refresh ahead requires loader function")
+	}
+
+	if b.conf.StaleTTL < 0 {
+		return nil, errors.New("invalid stale time to live")
+	}
+
+	if b.conf.StaleTTL > 0 && b.loadFunc == nil {
+		return nil, errors.New("stale time to live requires loader function")
+	}
+
+	if b.conf.XFetchBeta < 0 {
+		return nil, errors.New("invalid xfetch beta")
+	}
+
+	if b.conf.XFetchBeta > 0 && b.loadFunc == nil {
+		return nil, errors.New("xfetch requires loader function")
+	}
+
+	if b.conf.EventBuffer < 0 {
+		return nil, errors.New("invalid event buffer")
+	}
+
+	if b.conf.ExpirationInterval < 0 {
+		return nil, errors.New("invalid expiration interval")
+	}
+
 	itemsToPrune := uint32(10)
 	if b.conf.ItemsToPrune > 0 {
 		itemsToPrune = b.conf.ItemsToPrune
 	}
 
 	shards := make([]iShard, 0, int(b.conf.Shards))
+	flights := make([]*flightGroup, 0, int(b.conf.Shards))
 	counter := newCounter(b.conf.MaxSize)
 	timer := newTimer()
+	evictions := newEvictQueue(b.conf.OnEvict)
+
+	eventBuffer := 1024
+	if b.conf.EventBuffer > 0 {
+		eventBuffer = b.conf.EventBuffer
+	}
+	events := newEventBus(b.conf.clock(), eventBuffer)
+	stats := make([]statsCounter, b.conf.Shards)
 
 	cleanLimit := 1000
 	if v := int(counter.Limit()) / 10; v > cleanLimit {
@@ -77,12 +308,38 @@ func (b *builder) Build() (*Cache, error) {
 	}
 	chClean := make(chan struct{}, cleanLimit)
 
+	var wheel *timingWheel
+	if b.conf.ExpirationInterval > 0 {
+		wheel = newTimingWheel(b.conf.ExpirationInterval, timeNowLRU(b.conf.clock(), 0))
+	}
+
 	for i := 0; i < b.conf.Shards; i++ {
+		notifier := newNotifier(events, evictions, &stats[i], wheel)
+		capacity := b.conf.shardCapacity(i)
+
 		var shard iShard
 		switch b.conf.Kind {
 		case KindLRU:
 
-			shard = newShardRU(chClean, counter, timer, b.conf, b.loadFunc)
+			shard = newShardRU(chClean, counter, timer, notifier, b.conf)
+		case KindLFU:
+
+			shard = newShardLFU(chClean, counter, timer, notifier, b.conf)
+		case KindTinyLFU:
+
+			shard = newShardList(counter, notifier, b.conf, capacity, newPolicyTinyLFU(capacity))
+		case KindARC:
+
+			shard = newShardList(counter, notifier, b.conf, capacity, newPolicyARC(capacity))
+		case KindSIEVE:
+
+			shard = newShardList(counter, notifier, b.conf, capacity, newPolicySIEVE())
+		case KindS3FIFO:
+
+			shard = newShardList(counter, notifier, b.conf, capacity, newPolicyS3FIFO(capacity))
+		case KindExactLRU:
+
+			shard = newShardList(counter, notifier, b.conf, capacity, newPolicyExactLRU())
 		default:
 			return nil, errors.New("invalid kind of cache")
 		}
@@ -91,17 +348,41 @@ func (b *builder) Build() (*Cache, error) {
 	}
 
 	ctx, ctxCancel := context.WithCancel(context.Background())
+	for range shards {
+		flights = append(flights, newFlightGroup(ctx))
+	}
 	c := &Cache{
 		maxShardIndex: uint64(len(shards)) - 1,
 		shards:        shards,
+		flights:       flights,
+		loadFunc:      b.loadFunc,
+		bulkLoadFunc:  b.bulkLoadFunc,
+		errorTTL:      b.conf.ErrorTTL,
+		notFoundTTL:   b.conf.NotFoundTTL,
+		cacheableErr:  b.conf.CacheableError,
+		refreshAhead:  b.conf.RefreshAhead,
+		ttl:           b.conf.TTL,
+		staleTTL:      b.conf.StaleTTL,
+		xfetchBeta:    b.conf.XFetchBeta,
 		counter:       counter,
 		itemsToPrune:  itemsToPrune,
 		ctx:           ctx,
 		ctxCancel:     ctxCancel,
 		chClean:       chClean,
 		timer:         timer,
+		clock:         b.conf.clock(),
+		evictions:     evictions,
+		events:        events,
+		stats:         stats,
+		wheel:         wheel,
+	}
+	if b.conf.BatchWindow > 0 {
+		c.batcher = newBatcher(c, b.conf.BatchWindow, b.conf.MaxBatchSize)
 	}
 	c.runCleaner()
+	if evictions != nil {
+		c.runEvictions()
+	}
 
 	return c, nil
 }
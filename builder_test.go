@@ -28,6 +28,84 @@ func TestBuilder(t *testing.T) {
 		require.Nil(t, c)
 	}
 
+	for _, b := range []*builder{New(16, 10).TinyLFU(), New(16, 10).ARC(), New(16, 10).SIEVE(), New(16, 10).S3FIFO(), New(16, 10).ExactLRU()} {
+		c, err := b.Build()
+		require.EqualError(t, err, "count of shards exceeds size")
+		require.Nil(t, c)
+	}
+
+	{
+		c, err := New(1, 1).LRU().EvictionSamples(-1).Build()
+		require.EqualError(t, err, "invalid count of eviction samples")
+		require.Nil(t, c)
+	}
+
+	for _, b := range []*builder{New(1, 1).LRU().ErrorTTL(-1), New(1, 1).LRU().NotFoundTTL(-1)} {
+		c, err := b.Build()
+		require.EqualError(t, err, "invalid time to live of errors")
+		require.Nil(t, c)
+	}
+
+	for _, b := range []*builder{New(1, 1).LRU().BatchWindow(-1), New(1, 1).LRU().MaxBatchSize(-1)} {
+		c, err := b.Build()
+		require.EqualError(t, err, "invalid batch window")
+		require.Nil(t, c)
+	}
+
+	{
+		c, err := New(1, 1).LRU().BatchWindow(time.Millisecond).Build()
+		require.EqualError(t, err, "batch window requires bulk loader function")
+		require.Nil(t, c)
+	}
+
+	for _, i := range []float64{-0.1, 1} {
+		c, err := New(1, 1).LRU().RefreshAhead(i).Build()
+		require.EqualError(t, err, "invalid refresh ahead fraction")
+		require.Nil(t, c)
+	}
+
+	{
+		c, err := New(1, 1).LRU().RefreshAhead(0.5).Build()
+		require.EqualError(t, err, "refresh ahead requires loader function")
+		require.Nil(t, c)
+	}
+
+	{
+		c, err := New(1, 1).LRU().StaleTTL(-1).Build()
+		require.EqualError(t, err, "invalid stale time to live")
+		require.Nil(t, c)
+	}
+
+	{
+		c, err := New(1, 1).LRU().StaleTTL(time.Second).Build()
+		require.EqualError(t, err, "stale time to live requires loader function")
+		require.Nil(t, c)
+	}
+
+	{
+		c, err := New(1, 1).LRU().XFetch(-1).Build()
+		require.EqualError(t, err, "invalid xfetch beta")
+		require.Nil(t, c)
+	}
+
+	{
+		c, err := New(1, 1).LRU().XFetch(1).Build()
+		require.EqualError(t, err, "xfetch requires loader function")
+		require.Nil(t, c)
+	}
+
+	{
+		c, err := New(1, 1).LRU().ExpirationInterval(-1).Build()
+		require.EqualError(t, err, "invalid expiration interval")
+		require.Nil(t, c)
+	}
+
+	{
+		c, err := New(1, 1).LRU().EventBuffer(-1).Build()
+		require.EqualError(t, err, "invalid event buffer")
+		require.Nil(t, c)
+	}
+
 	{
 		c, err := New(1, 1).Build()
 		require.EqualError(t, err, "invalid kind of cache")
@@ -37,4 +115,41 @@ func TestBuilder(t *testing.T) {
 	c, err := New(1, 1).LRU().Build()
 	require.NoError(t, err)
 	require.NotNil(t, c)
+
+	c, err = New(1, 1).LFU().Build()
+	require.NoError(t, err)
+	require.NotNil(t, c)
+
+	c, err = New(1, 1).TinyLFU().Build()
+	require.NoError(t, err)
+	require.NotNil(t, c)
+
+	c, err = New(1, 1).ARC().Build()
+	require.NoError(t, err)
+	require.NotNil(t, c)
+
+	c, err = New(1, 1).SIEVE().Build()
+	require.NoError(t, err)
+	require.NotNil(t, c)
+
+	c, err = New(1, 1).S3FIFO().Build()
+	require.NoError(t, err)
+	require.NotNil(t, c)
+
+	c, err = New(1, 1).ExactLRU().Build()
+	require.NoError(t, err)
+	require.NotNil(t, c)
+}
+
+func TestConfigShardCapacity(t *testing.T) {
+
+	// the remainder of MaxSize is handed out to the first shards
+	conf := &Config{Shards: 4, MaxSize: 10}
+	capacities := make([]int64, 0, conf.Shards)
+	for i := 0; i < conf.Shards; i++ {
+		capacities = append(capacities, conf.shardCapacity(i))
+	}
+	require.Equal(t, []int64{3, 3, 2, 2}, capacities)
+
+	require.Equal(t, int64(1), (&Config{}).shardCapacity(0))
 }
@@ -0,0 +1,214 @@
+package scache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// BulkLoadFunc returns the values of the keys, the keys which are missing
+// in the result aren't found.
+type BulkLoadFunc func(ctx context.Context, keys []interface{}) (values map[interface{}]interface{}, err error)
+
+// GetMulti returns the values of the keys, the keys which aren't found are
+// missing in the result. The missing keys are loaded by one call of the bulk
+// loader function or by the loader function if the bulk one isn't set.
+// The stale values are returned like in Get while they're reloaded in the background.
+// The error of the load is returned with the values which were got.
+func (c *Cache) GetMulti(keys []interface{}) (values map[interface{}]interface{}, err error) {
+	return c.GetMultiCtx(context.Background(), keys)
+}
+
+// GetMultiCtx is GetMulti with the context which is passed to the loader function.
+func (c *Cache) GetMultiCtx(ctx context.Context, keys []interface{}) (values map[interface{}]interface{}, err error) {
+
+	var (
+		missing []interface{}
+		ids     []int
+	)
+
+	values = make(map[interface{}]interface{}, len(keys))
+
+	for _, key := range keys {
+		bID, err := c.shardID(key)
+		if err != nil {
+			return nil, err
+		}
+
+		value, _, err := c.getFromShard(bID, key)
+		if err == ErrNotFound {
+			if c.loadFunc != nil || c.bulkLoadFunc != nil {
+				missing = append(missing, key)
+				ids = append(ids, bID)
+			}
+			continue
+		}
+
+		if e, ok := value.(*negativeEntry); !ok {
+			values[key] = value
+		} else if !errors.Is(e.err, ErrNotFound) {
+			return values, e.err
+		}
+	}
+
+	if len(missing) == 0 {
+		return values, nil
+	}
+
+	if c.bulkLoadFunc == nil {
+		for i, key := range missing {
+			value, err := c.load(ctx, ids[i], key)
+			if err == nil {
+				values[key] = value
+			} else if !errors.Is(err, ErrNotFound) {
+				return values, err
+			}
+		}
+
+		return values, nil
+	}
+
+	err = c.loadMulti(ctx, missing, ids, values)
+
+	return values, err
+}
+
+// loadOne loads the key by the bulk loader function.
+func (c *Cache) loadOne(ctx context.Context, bID int, key interface{}) (value interface{}, err error) {
+
+	values := make(map[interface{}]interface{}, 1)
+	if err = c.loadMulti(ctx, []interface{}{key}, []int{bID}, values); err != nil {
+		return
+	}
+
+	value, exist := values[key]
+	if !exist {
+		err = ErrNotFound
+	}
+
+	return
+}
+
+// loadMulti loads the keys by one call of the bulk loader function without
+// the locks of the shards. The keys which are loaded by other calls aren't
+// passed to the bulk loader function, the caller waits for them.
+func (c *Cache) loadMulti(ctx context.Context, keys []interface{}, ids []int, values map[interface{}]interface{}) (err error) {
+
+	calls := make([]*call, len(keys))
+
+	b := newBatch(ctx)
+	for i, key := range keys {
+		calls[i] = b.join(c.flights[ids[i]], ids[i], key)
+	}
+	c.runBatch(b)
+
+	for i, key := range keys {
+		value, loadErr := loadedValue(c.flights[ids[i]].wait(ctx, calls[i]))
+		if loadErr == nil {
+			values[key] = value
+		} else if !errors.Is(loadErr, ErrNotFound) && err == nil {
+			err = loadErr
+		}
+	}
+
+	return
+}
+
+// runBatch starts the load of the keys of the batch.
+func (c *Cache) runBatch(b *batch) {
+	if len(b.keys) > 0 {
+		go c.runBulk(b)
+	} else {
+		b.cancel()
+	}
+}
+
+func (c *Cache) runBulk(b *batch) {
+
+	stop := afterFunc(c.ctx, b.cancel)
+
+	var (
+		values map[interface{}]interface{}
+		err    error
+		start  = c.clock.Now()
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			values, err = nil, fmt.Errorf("%w: %v", ErrLoaderPanic, r)
+		}
+
+		stop()
+		b.cancel()
+
+		delta := c.clock.Now().Sub(start)
+		// the call is counted by the shard of the first key
+		c.stats[b.ids[0]].Loaded(delta, err)
+
+		for i, key := range b.keys {
+			call := b.calls[i]
+			if err != nil {
+				call.value, call.err = c.loadResult(nil, 0, 0, err)
+			} else if value, exist := values[key]; exist {
+				call.value, call.err = c.loadResult(value, 0, 0, nil)
+			} else {
+				call.value, call.err = c.loadResult(nil, 0, 0, ErrNotFound)
+			}
+			call.value.(*loadResult).Delta = delta
+
+			c.flights[b.ids[i]].finish(key, call, c.storeLoaded(b.ids[i], key))
+		}
+	}()
+
+	if err = b.ctx.Err(); err == nil {
+		values, err = c.bulkLoadFunc(b.ctx, b.keys)
+	}
+}
+
+// batch is the keys which are loaded by one call of the bulk loader function.
+type batch struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	keys   []interface{}
+	ids    []int
+	calls  []*call
+	// count of the keys which have waiters, the load is cancelled
+	// when the waiters of all keys stopped waiting
+	active int32
+}
+
+func newBatch(ctx context.Context) *batch {
+
+	ctx, cancel := context.WithCancel(withoutCancel(ctx))
+
+	return &batch{
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+// join adds the caller to the waiters of the load of the key, the key is
+// added to the batch if there isn't the load of the key in flight.
+func (b *batch) join(g *flightGroup, bID int, key interface{}) *call {
+
+	var once sync.Once
+	release := func() {
+		once.Do(func() {
+			if atomic.AddInt32(&b.active, -1) == 0 {
+				b.cancel()
+			}
+		})
+	}
+
+	call, created := g.join(key, release)
+	if created {
+		atomic.AddInt32(&b.active, 1)
+		b.keys = append(b.keys, key)
+		b.ids = append(b.ids, bID)
+		b.calls = append(b.calls, call)
+	}
+
+	return call
+}
@@ -0,0 +1,207 @@
+package scache
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/khevse/scache/scachetest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCacheGetMulti(t *testing.T) {
+
+	var (
+		mu    sync.Mutex
+		calls [][]interface{}
+	)
+
+	bulkLoadFunc := func(ctx context.Context, keys []interface{}) (values map[interface{}]interface{}, err error) {
+		mu.Lock()
+		calls = append(calls, sortedKeys(keys))
+		mu.Unlock()
+
+		values = make(map[interface{}]interface{})
+		for _, key := range keys {
+			if key != "missing" {
+				values[key] = key
+			}
+		}
+		return
+	}
+
+	cache, err := New(4, 100).LRU().BulkLoaderFunc(bulkLoadFunc).Build()
+	require.NoError(t, err)
+	defer cache.Close()
+
+	cache.Set("a", "A")
+
+	values, err := cache.GetMulti([]interface{}{"a", "b", "c", "missing"})
+	require.NoError(t, err)
+	require.Equal(t, map[interface{}]interface{}{"a": "A", "b": "b", "c": "c"}, values)
+	require.Equal(t, [][]interface{}{{"b", "c", "missing"}}, calls)
+
+	// the loaded values are stored
+	values, err = cache.GetMulti([]interface{}{"b", "c", "missing"})
+	require.NoError(t, err)
+	require.Equal(t, map[interface{}]interface{}{"b": "b", "c": "c"}, values)
+	require.Equal(t, [][]interface{}{{"b", "c", "missing"}, {"missing"}}, calls)
+
+	// Get uses the bulk loader function
+	val, err := cache.Get("d")
+	require.NoError(t, err)
+	require.Equal(t, "d", val)
+
+	_, err = cache.Get("missing")
+	require.Equal(t, ErrNotFound, err)
+
+	_, err = cache.GetMulti([]interface{}{"a", nil})
+	require.Equal(t, ErrKeyIsNil, err)
+}
+
+func TestCacheGetMultiWithLoadFunc(t *testing.T) {
+
+	var calls int32
+	loadFunc := func(key interface{}) (val interface{}, err error) {
+		atomic.AddInt32(&calls, 1)
+		if key == "missing" {
+			err = ErrNotFound
+		} else {
+			val = key
+		}
+		return
+	}
+
+	cache, err := New(4, 100).LRU().LoaderFunc(loadFunc).Build()
+	require.NoError(t, err)
+	defer cache.Close()
+
+	values, err := cache.GetMulti([]interface{}{"a", "b", "missing"})
+	require.NoError(t, err)
+	require.Equal(t, map[interface{}]interface{}{"a": "a", "b": "b"}, values)
+	require.Equal(t, int32(3), atomic.LoadInt32(&calls))
+}
+
+func TestCacheGetMultiStale(t *testing.T) {
+
+	clock := scachetest.NewFakeClock(time.Now())
+
+	var calls int32
+	loadFunc := func(key interface{}) (val interface{}, err error) {
+		return atomic.AddInt32(&calls, 1), nil
+	}
+
+	cache, err := New(4, 100).LRU().TTL(time.Minute).StaleTTL(time.Hour).Clock(clock).LoaderFunc(loadFunc).Build()
+	require.NoError(t, err)
+	defer cache.Close()
+
+	values, err := cache.GetMulti([]interface{}{"key"})
+	require.NoError(t, err)
+	require.Equal(t, map[interface{}]interface{}{"key": int32(1)}, values)
+
+	clock.Advance(2 * time.Minute)
+
+	// the stale value is returned, the item is reloaded in the background
+	values, err = cache.GetMulti([]interface{}{"key"})
+	require.NoError(t, err)
+	require.Equal(t, map[interface{}]interface{}{"key": int32(1)}, values)
+
+	require.Eventually(t, func() bool {
+		for _, f := range cache.flights {
+			if atomic.LoadInt32(&f.inflight) != 0 {
+				return false
+			}
+		}
+		return atomic.LoadInt32(&calls) == 2
+	}, time.Second, time.Millisecond)
+
+	values, err = cache.GetMulti([]interface{}{"key"})
+	require.NoError(t, err)
+	require.Equal(t, map[interface{}]interface{}{"key": int32(2)}, values)
+}
+
+func TestCacheGetMultiError(t *testing.T) {
+
+	errLoad := errors.New("failed to upload")
+	bulkLoadFunc := func(ctx context.Context, keys []interface{}) (values map[interface{}]interface{}, err error) {
+		if len(keys) > 1 {
+			panic("test")
+		}
+		return nil, errLoad
+	}
+
+	cache, err := New(4, 100).LRU().BulkLoaderFunc(bulkLoadFunc).Build()
+	require.NoError(t, err)
+	defer cache.Close()
+
+	cache.Set("a", "A")
+
+	values, err := cache.GetMulti([]interface{}{"a", "b"})
+	require.Equal(t, errLoad, err)
+	require.Equal(t, map[interface{}]interface{}{"a": "A"}, values)
+
+	_, err = cache.GetMulti([]interface{}{"b", "c"})
+	require.True(t, errors.Is(err, ErrLoaderPanic))
+	require.Equal(t, int64(1), cache.Count())
+}
+
+func TestCacheGetMultiSingleFlight(t *testing.T) {
+
+	var (
+		calls   int32
+		release = make(chan struct{})
+	)
+
+	bulkLoadFunc := func(ctx context.Context, keys []interface{}) (values map[interface{}]interface{}, err error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+
+		values = make(map[interface{}]interface{})
+		for _, key := range keys {
+			values[key] = key
+		}
+		return
+	}
+
+	cache, err := New(4, 100).LRU().BulkLoaderFunc(bulkLoadFunc).Build()
+	require.NoError(t, err)
+	defer cache.Close()
+
+	done := make(chan map[interface{}]interface{})
+	go func() {
+		values, _ := cache.GetMulti([]interface{}{"a", "b"})
+		done <- values
+	}()
+
+	require.Eventually(t, func() bool {
+		return atomic.LoadInt32(&calls) == 1
+	}, time.Second, time.Millisecond)
+
+	// the keys which are in flight aren't loaded again
+	go func() {
+		values, _ := cache.GetMulti([]interface{}{"a", "b"})
+		done <- values
+	}()
+
+	time.Sleep(50 * time.Millisecond) // the second call waits for the first one
+	close(release)
+
+	for i := 0; i < 2; i++ {
+		require.Equal(t, map[interface{}]interface{}{"a": "a", "b": "b"}, <-done)
+	}
+	require.Equal(t, int32(1), atomic.LoadInt32(&calls))
+}
+
+func sortedKeys(keys []interface{}) []interface{} {
+
+	sorted := append([]interface{}(nil), keys...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].(string) < sorted[j].(string)
+	})
+
+	return sorted
+}
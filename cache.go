@@ -4,6 +4,9 @@ import (
 	"context"
 	"errors"
 	"log"
+	"math"
+	"math/rand"
+	"reflect"
 	"sync"
 	"time"
 )
@@ -12,16 +15,47 @@ var (
 	ErrNotFound             = errors.New("not found")
 	ErrKeyIsNil             = errors.New("key is nil")
 	ErrInvlidKeyTypeForHash = errors.New("invalid key type for hash function")
+	ErrLoaderPanic          = errors.New("loader function panicked")
 )
 
+// NoExpire is the lifetime of the item which doesn't expire
+// regardless of the default lifetime of the cache.
+const NoExpire time.Duration = -1
+
 type LoadFunc func(key interface{}) (value interface{}, err error)
 
+type LoadFuncCtx func(ctx context.Context, key interface{}) (value interface{}, err error)
+
+// LoadFuncWithMeta returns the value with its lifetime and weight, the default
+// lifetime and the result of the weigher are used if they are zero.
+// The value doesn't expire if the lifetime is NoExpire and it isn't cached
+// if the lifetime is negative otherwise.
+type LoadFuncWithMeta func(ctx context.Context, key interface{}) (value interface{}, ttl time.Duration, cost int64, err error)
+
+type WeighFunc func(key interface{}, value interface{}) int64
+
 type Cache struct {
 	maxShardIndex uint64
 	shards        []iShard
+	flights       []*flightGroup
+	loadFunc      LoadFuncWithMeta
+	bulkLoadFunc  BulkLoadFunc
+	batcher       *batcher
+	refreshAhead  float64
+	ttl           time.Duration
+	staleTTL      time.Duration
+	xfetchBeta    float64
+	errorTTL      time.Duration
+	notFoundTTL   time.Duration
+	cacheableErr  func(err error) bool
 	counter       *counter
 	itemsToPrune  uint32
 	timer         *timer
+	clock         Clock
+	wheel         *timingWheel
+	evictions     *evictQueue
+	events        *eventBus
+	stats         []statsCounter // by the shards
 	wg            sync.WaitGroup
 	ctx           context.Context
 	ctxCancel     func()
@@ -31,48 +65,377 @@ type Cache struct {
 func (c *Cache) Close() {
 	c.ctxCancel()
 	c.wg.Wait()
+
+	if c.evictions != nil {
+		// the items which were removed before the cleaner stopped
+		c.evictions.flush()
+	}
+
+	c.events.Close()
+}
+
+// Subscribe returns the channel of the changes of items which match the filter,
+// e.g. EventInsert|EventUpdate, all changes are sent if it's 0. The events are
+// dropped if the subscriber is slow and the buffer of the channel is full.
+// The channel is closed by the cancel function or Close.
+func (c *Cache) Subscribe(filter EventType) (<-chan Event, func()) {
+	return c.events.Subscribe(filter)
+}
+
+// Stats returns the snapshot of the statistics of the cache.
+func (c *Cache) Stats() Stats {
+
+	stats := Stats{Evictions: make(map[RemovalReason]uint64)}
+	for i := range c.stats {
+		c.stats[i].addTo(&stats)
+	}
+
+	return stats
+}
+
+// ResetStats sets the counters of the statistics to zero.
+func (c *Cache) ResetStats() {
+	for i := range c.stats {
+		c.stats[i].reset()
+	}
+}
+
+// DroppedEvents returns the count of the events which were dropped
+// because the subscribers were slow.
+func (c *Cache) DroppedEvents() int64 {
+	return c.events.Dropped()
 }
 
 func (c *Cache) Set(key interface{}, value interface{}) {
 
 	bID, err := c.shardID(key)
 	if err == nil {
+		c.flights[bID].Forget(key)
 		c.shards[bID].Set(key, value)
+		c.schedule(key, 0)
+		c.waitCleaner()
 	}
 }
 
+// SetExp sets value with the custom lifetime, the default lifetime is used
+// if it's 0 and the value doesn't expire if it's NoExpire. The key is deleted
+// if the lifetime is negative otherwise.
 func (c *Cache) SetExp(key interface{}, value interface{}, ttl time.Duration) {
 
+	if ttl < 0 && ttl != NoExpire {
+		c.Del(key)
+		return
+	}
+
 	bID, err := c.shardID(key)
 	if err == nil {
+		c.flights[bID].Forget(key)
 		c.shards[bID].SetExp(key, value, ttl)
+		c.schedule(key, ttl)
+		c.waitCleaner()
+	}
+}
+
+// SetSliding sets value with the sliding expiration regardless of the default,
+// every Get moves the expiration time forward by the lifetime. The key is deleted
+// if the lifetime is negative and it isn't NoExpire.
+func (c *Cache) SetSliding(key interface{}, value interface{}, ttl time.Duration) {
+
+	if ttl < 0 && ttl != NoExpire {
+		c.Del(key)
+		return
+	}
+
+	bID, err := c.shardID(key)
+	if err == nil {
+		c.flights[bID].Forget(key)
+		c.shards[bID].Replace(key, value, itemOptions{TTL: ttl, Sliding: true})
+		c.schedule(key, ttl)
+		c.waitCleaner()
+	}
+}
+
+// SetExpireAt sets value which expires at the time, the key is deleted
+// if the time has passed.
+func (c *Cache) SetExpireAt(key interface{}, value interface{}, at time.Time) {
+
+	ttl := at.Sub(c.clock.Now())
+	if ttl <= 0 {
+		c.Del(key)
+		return
+	}
+
+	c.SetExp(key, value, ttl)
+}
+
+// SetWithCost sets value with the custom weight instead of the result of the weigher.
+func (c *Cache) SetWithCost(key interface{}, value interface{}, cost int64) {
+
+	bID, err := c.shardID(key)
+	if err == nil {
+		c.flights[bID].Forget(key)
+		c.shards[bID].SetWithCost(key, value, cost)
+		c.schedule(key, 0)
+		c.waitCleaner()
 	}
 }
 
 func (c *Cache) Get(key interface{}) (value interface{}, err error) {
+	return c.GetCtx(context.Background(), key)
+}
+
+// GetCtx returns the value, the context is passed to the loader function.
+// The caller stops waiting for the load when the context is done, but
+// the load is cancelled only if there aren't other callers of the key.
+func (c *Cache) GetCtx(ctx context.Context, key interface{}) (value interface{}, err error) {
+	value, _, err = c.GetWithStale(ctx, key)
+	return
+}
+
+// GetWithStale is GetCtx which returns the flag of the stale value. The stale
+// value is returned during the stale period after the end of its lifetime
+// while it's reloaded in the background.
+func (c *Cache) GetWithStale(ctx context.Context, key interface{}) (value interface{}, stale bool, err error) {
 
 	bID, err := c.shardID(key)
 	if err == nil {
-		value, err = c.shards[bID].Get(key)
+		value, stale, err = c.getFromShard(bID, key)
+		if err == ErrNotFound {
+			switch {
+			case c.batcher != nil:
+				value, err = c.batcher.load(ctx, bID, key)
+			case c.loadFunc != nil:
+				value, err = c.load(ctx, bID, key)
+			case c.bulkLoadFunc != nil:
+				value, err = c.loadOne(ctx, bID, key)
+			}
+		} else if e, ok := value.(*negativeEntry); ok {
+			value, err = nil, e.err
+		}
+	}
+
+	return
+}
+
+// getFromShard returns the value of the key from the shard. The value which is
+// stale or which has to be refreshed ahead is reloaded in the background.
+func (c *Cache) getFromShard(bID int, key interface{}) (value interface{}, stale bool, err error) {
+
+	var meta itemMeta
+	value, meta, err = c.shards[bID].GetWithMeta(key)
+	c.stats[bID].Hit(err == nil)
+	if err == nil && meta.Expire != 0 {
+		// the stale period is the part of the lifetime of the item in the shard
+		meta.Expire -= int64(c.staleTTL)
+
+		switch now := timeNowLRU(c.clock, 0); {
+		case meta.Expire < now:
+			stale = true
+			c.reload(bID, key)
+		case c.refreshAhead > 0 && meta.Expire-now <= int64(float64(meta.TTL)*c.refreshAhead),
+			c.xfetchBeta > 0 && c.expiresEarly(meta, now):
+			c.reload(bID, key)
+		}
+	}
+
+	return
+}
+
+// load calls the loader function without the lock of the shard, concurrent
+// loads of the same key are merged into one. The loaded value isn't stored
+// if the key was set or deleted during the load.
+func (c *Cache) load(ctx context.Context, bID int, key interface{}) (value interface{}, err error) {
+
+	shard := c.shards[bID]
+
+	return loadedValue(c.flights[bID].Do(ctx, key,
+		func(ctx context.Context) (interface{}, error) {
+			// the previous load could be finished after the miss
+			if value, err := shard.Get(key); err == nil {
+				return &loadResult{Value: value}, nil
+			}
+
+			start := c.clock.Now()
+			value, ttl, cost, err := c.loadFunc(ctx, key)
+			delta := c.clock.Now().Sub(start)
+			c.stats[bID].Loaded(delta, err)
+
+			res, err := c.loadResult(value, ttl, cost, err)
+			res.Delta = delta
+
+			return res, err
+		},
+		c.storeLoaded(bID, key)))
+}
+
+// expiresEarly returns true if the item has to be reloaded before the end of
+// its lifetime (XFetch). The probability grows as the end of the lifetime
+// approaches and it's higher for the items which are loaded longer.
+func (c *Cache) expiresEarly(meta itemMeta, now int64) bool {
+	return meta.Delta > 0 &&
+		float64(meta.Expire-now) <= -float64(meta.Delta)*c.xfetchBeta*math.Log(rand.Float64())
+}
+
+// reload loads the item in the background. The errors of the loader
+// function aren't cached, the current value is kept until it expires.
+func (c *Cache) reload(bID int, key interface{}) {
+
+	c.flights[bID].Go(key,
+		func(ctx context.Context) (interface{}, error) {
+			start := c.clock.Now()
+			value, ttl, cost, err := c.loadFunc(ctx, key)
+			delta := c.clock.Now().Sub(start)
+			c.stats[bID].Loaded(delta, err)
+
+			return &loadResult{Value: value, TTL: ttl, Cost: cost, Delta: delta}, err
+		},
+		func(res interface{}, storable func() bool) {
+			r := res.(*loadResult)
+			opts := itemOptions{TTL: r.TTL, Cost: r.Cost, Delta: r.Delta, Storable: storable}
+			if r.cacheable() && c.shards[bID].Replace(key, r.Value, opts) {
+				c.schedule(key, r.TTL)
+				c.events.Publish(EventLoad, key, r.Value)
+				c.waitCleaner()
+			}
+		})
+}
+
+// loadResult returns the result of the loader function, the error
+// is replaced by the negative entry if the error is cached.
+func (c *Cache) loadResult(value interface{}, ttl time.Duration, cost int64, err error) (*loadResult, error) {
+
+	if err != nil {
+		if ttl := c.negativeTTL(err); ttl > 0 {
+			// the error is stored instead of the value
+			return &loadResult{Value: &negativeEntry{err: err}, TTL: ttl, Cost: 1}, nil
+		}
+	}
+
+	return &loadResult{Value: value, TTL: ttl, Cost: cost}, err
+}
+
+// storeLoaded returns the function which stores the result of the load of the key.
+func (c *Cache) storeLoaded(bID int, key interface{}) storeFunc {
+	return func(res interface{}, storable func() bool) {
+		r := res.(*loadResult)
+		opts := itemOptions{TTL: r.TTL, Cost: r.Cost, Delta: r.Delta, Storable: storable}
+		if r.cacheable() && c.shards[bID].Add(key, r.Value, opts) {
+			c.schedule(key, r.TTL)
+			c.events.Publish(EventLoad, key, r.Value)
+			c.waitCleaner()
+		}
+	}
+}
+
+// schedule adds the key to the timing wheel, so the key is deleted when
+// it expires. The stale period is the part of the lifetime of the item.
+func (c *Cache) schedule(key interface{}, ttl time.Duration) {
+	if c.wheel != nil {
+		expire, _ := expireTime(timeNowLRU(c.clock, 0), ttl, c.ttl, c.staleTTL)
+		c.wheel.Schedule(key, expire)
+	}
+}
+
+// loadedValue returns the value of the result of the load.
+func loadedValue(res interface{}, err error) (value interface{}, _ error) {
+
+	if r, ok := res.(*loadResult); ok {
+		value = r.Value
+	}
+
+	if e, ok := value.(*negativeEntry); ok {
+		value, err = nil, e.err
+	}
+
+	return value, err
+}
+
+// negativeTTL returns the lifetime of the error of the loader function,
+// the error isn't cached if it's 0.
+func (c *Cache) negativeTTL(err error) (ttl time.Duration) {
+
+	switch {
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		// the load was cancelled, it isn't the result of the load
+	case errors.Is(err, ErrNotFound):
+		ttl = c.notFoundTTL
+	case c.cacheableErr == nil || c.cacheableErr(err):
+		ttl = c.errorTTL
 	}
 
 	return
 }
 
+type loadResult struct {
+	Value interface{}
+	TTL   time.Duration
+	Cost  int64
+	Delta time.Duration // duration of the load
+}
+
+// cacheable returns false if the lifetime of the loaded value is negative
+// and it isn't NoExpire, so the value isn't stored.
+func (r *loadResult) cacheable() bool {
+	return r.TTL >= 0 || r.TTL == NoExpire
+}
+
+// negativeEntry is the cached error of the loader function.
+type negativeEntry struct {
+	err error
+}
+
 func (c *Cache) Del(key interface{}) (ok bool) {
+	return c.remove(key, RemovalDeleted)
+}
+
+// remove deletes the key, the reason is passed to the eviction callback.
+func (c *Cache) remove(key interface{}, reason RemovalReason) (ok bool) {
+
+	bID, err := c.shardID(key)
+	if err == nil {
+		c.flights[bID].Forget(key)
+		ok = c.shards[bID].Remove(key, reason)
+		if c.wheel != nil {
+			c.wheel.Cancel(key)
+		}
+	}
+
+	return
+}
+
+// delExpired deletes the key if it's expired.
+func (c *Cache) delExpired(key interface{}) (ok bool) {
 
 	bID, err := c.shardID(key)
 	if err == nil {
-		ok = c.shards[bID].Del(key)
+		_, ok = c.shards[bID].DelExpired(key)
 	}
 
 	return
 }
 
+// Clear deletes all keys, the loads which are in flight aren't stored.
+func (c *Cache) Clear() {
+
+	if c.wheel != nil {
+		c.wheel.Clear()
+	}
+
+	for bID, s := range c.shards {
+		c.flights[bID].ForgetAll()
+		s.Clear()
+	}
+}
+
 func (c *Cache) Count() (count int64) {
 	return c.counter.Count()
 }
 
+// Weight returns the total weight of items.
+func (c *Cache) Weight() (weight int64) {
+	return c.counter.Weight()
+}
+
 func (c *Cache) runCleaner() {
 
 	c.wg.Add(1)
@@ -81,45 +444,115 @@ func (c *Cache) runCleaner() {
 		defer c.wg.Done()
 
 		var (
-			removed     int
 			expiredKeys = make([]interface{}, 0, 10000)
 			oldest      = newListWithOldEntriesLRU(int(c.itemsToPrune))
+			chExpire    <-chan time.Time
 		)
 
+		if c.wheel != nil {
+			ticker := time.NewTicker(c.wheel.tick)
+			defer ticker.Stop()
+			chExpire = ticker.C
+		}
+
 		for {
 			select {
 			case <-c.ctx.Done():
 				return
-			case <-c.chClean:
-			}
-
-			if removed > 0 {
-				removed--
+			case <-chExpire:
+				c.deleteExpired(&expiredKeys)
 				continue
+			case <-c.chClean:
 			}
 
-			if key, ok := oldest.Next(); ok {
-				c.Del(key)
-				continue
-			}
+			// the weight of the new item can be greater than the weight of
+			// the oldest item, so the items are removed until the overflow ends
+			for c.counter.Overflow() && c.ctx.Err() == nil {
 
-			oldest.Clear()
+				if c.removeOldest(oldest) {
+					continue
+				}
 
-			for _, s := range c.shards {
-				expiredKeys = expiredKeys[:0]
-				s.GetForRemove(&expiredKeys, oldest)
-				for _, k := range expiredKeys {
-					if k != nil {
-						if ok := c.Del(k); ok {
-							removed++
+				oldest.Clear()
+
+				removed := 0
+				for _, s := range c.shards {
+					expiredKeys = expiredKeys[:0]
+					s.GetForRemove(&expiredKeys, oldest)
+					for _, k := range expiredKeys {
+						if k != nil {
+							if ok := c.delExpired(k); ok {
+								removed++
+							}
 						}
 					}
 				}
+
+				if removed == 0 && !c.removeOldest(oldest) {
+					break // nothing to remove
+				}
 			}
 		}
 	}()
 }
 
+// waitCleaner waits for the cleaner if it hasn't processed the overflow
+// notifications yet, so the writers don't outpace the cleaner. The shards
+// don't wait for the cleaner, so it's called without the locks.
+func (c *Cache) waitCleaner() {
+	if len(c.chClean) == cap(c.chClean) && c.counter.Overflow() {
+		select {
+		case c.chClean <- struct{}{}:
+		case <-c.ctx.Done():
+		}
+	}
+}
+
+// deleteExpired deletes the keys which expired according to the timing wheel,
+// the keys which expiration time was moved are scheduled again.
+func (c *Cache) deleteExpired(expiredKeys *[]interface{}) {
+
+	*expiredKeys = (*expiredKeys)[:0]
+	c.wheel.Advance(timeNowLRU(c.clock, 0), expiredKeys)
+
+	for _, k := range *expiredKeys {
+		bID, err := c.shardID(k)
+		if err != nil {
+			continue
+		}
+
+		if expire, ok := c.shards[bID].DelExpired(k); !ok && expire != 0 {
+			c.wheel.Schedule(k, expire)
+		}
+	}
+}
+
+// runEvictions calls the eviction callback for the removed items
+// until the cache is closed.
+func (c *Cache) runEvictions() {
+
+	c.wg.Add(1)
+
+	go func() {
+		defer c.wg.Done()
+		c.evictions.run(c.ctx)
+	}()
+}
+
+// removeOldest deletes the first item from the list which still exists.
+func (c *Cache) removeOldest(oldest *listWithOldEntriesLRU) (ok bool) {
+
+	for !ok {
+		key, exist := oldest.Next()
+		if !exist {
+			break
+		}
+		ok = c.remove(key, RemovalEvicted)
+	}
+
+	return
+}
+
 func (c *Cache) shardID(key interface{}) (id int, err error) {
 
 	if key == nil {
@@ -132,17 +565,22 @@ func (c *Cache) shardID(key interface{}) (id int, err error) {
 		return // nothing do. it's the fastests case.
 	}
 
-	const prime64 = 1099511628211
+	val, err := hashKey(key)
+	if err != nil {
+		log.Println("failed to get key hash", err)
+		return
+	}
+
+	id = int(val & c.maxShardIndex)
+
+	return
+}
 
-	var val uint64
+func hashKey(key interface{}) (val uint64, err error) {
 
 	switch src := key.(type) {
 	case string:
-		// copy from https://golang.org/pkg/hash/fnv/#New64 (see method 'Write')
-		for _, symbol := range src {
-			val ^= uint64(symbol)
-			val *= prime64
-		}
+		val = hashString(src)
 
 	case uint8:
 		val = uint64(src)
@@ -169,13 +607,70 @@ func (c *Cache) shardID(key interface{}) (id int, err error) {
 	case uint:
 		val = uint64(src)
 	default:
-		err = ErrInvlidKeyTypeForHash
-		log.Println("failed to get key hash", err)
-		return
+		val, err = hashValue(reflect.ValueOf(key))
+	}
+
+	return
+}
 
+// hashValue returns the hash of the key of the named type, e.g. 'type UserID string',
+// or of the comparable composite type (struct, array, pointer), so all keys which
+// are valid for TypedCache can be hashed.
+func hashValue(v reflect.Value) (val uint64, err error) {
+
+	const prime64 = 1099511628211
+
+	switch v.Kind() {
+	case reflect.String:
+		val = hashString(v.String())
+	case reflect.Bool:
+		if v.Bool() {
+			val = 1
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		val = uint64(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		val = v.Uint()
+	case reflect.Float32, reflect.Float64:
+		val = uint64(v.Float())
+	case reflect.Complex64, reflect.Complex128:
+		c := v.Complex()
+		val = uint64(real(c))*prime64 ^ uint64(imag(c))
+	case reflect.Ptr, reflect.Chan, reflect.UnsafePointer:
+		// the low bits of the address are zeros because of the alignment
+		val, _ = spreadHash(uint64(v.Pointer()))
+	case reflect.Interface:
+		if !v.IsNil() {
+			val, err = hashValue(v.Elem())
+		}
+	case reflect.Array:
+		for i := 0; i < v.Len() && err == nil; i++ {
+			var h uint64
+			h, err = hashValue(v.Index(i))
+			val = (val ^ h) * prime64
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField() && err == nil; i++ {
+			var h uint64
+			h, err = hashValue(v.Field(i))
+			val = (val ^ h) * prime64
+		}
+	default:
+		err = ErrInvlidKeyTypeForHash
 	}
 
-	id = int(val & c.maxShardIndex)
+	return
+}
+
+func hashString(src string) (val uint64) {
+
+	const prime64 = 1099511628211
+
+	// copy from https://golang.org/pkg/hash/fnv/#New64 (see method 'Write')
+	for _, symbol := range src {
+		val ^= uint64(symbol)
+		val *= prime64
+	}
 
 	return
 }
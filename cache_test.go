@@ -1,14 +1,22 @@
 package scache
 
 import (
+	"context"
 	"errors"
 	"strconv"
+	"strings"
+	"sync"
 	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/khevse/scache/scachetest"
 	"github.com/stretchr/testify/require"
 )
 
+// allKinds are the kinds of the cache which are checked by the tests.
+var allKinds = []Kind{KindLRU, KindLFU, KindTinyLFU, KindARC, KindSIEVE, KindS3FIFO, KindExactLRU}
+
 func TestCache(t *testing.T) {
 
 	for _, testInfo := range []struct {
@@ -31,9 +39,57 @@ func TestCache(t *testing.T) {
 				}
 			},
 		},
+		{
+			Name: "RefreshAhead",
+			Func: func(kind Kind) func(*testing.T) {
+				return func(*testing.T) {
+					testCacheRefreshAhead(t, kind)
+				}
+			},
+		},
+		{
+			Name: "Stale",
+			Func: func(kind Kind) func(*testing.T) {
+				return func(*testing.T) {
+					testCacheStale(t, kind)
+				}
+			},
+		},
+		{
+			Name: "XFetch",
+			Func: func(kind Kind) func(*testing.T) {
+				return func(*testing.T) {
+					testCacheXFetch(t, kind)
+				}
+			},
+		},
+		{
+			Name: "SetExp",
+			Func: func(kind Kind) func(*testing.T) {
+				return func(*testing.T) {
+					testCacheSetExp(t, kind)
+				}
+			},
+		},
+		{
+			Name: "Sliding",
+			Func: func(kind Kind) func(*testing.T) {
+				return func(*testing.T) {
+					testCacheSliding(t, kind)
+				}
+			},
+		},
+		{
+			Name: "LoadFuncWithMeta",
+			Func: func(kind Kind) func(*testing.T) {
+				return func(*testing.T) {
+					testCacheLoadFuncWithMeta(t, kind)
+				}
+			},
+		},
 	} {
 
-		for _, kind := range []Kind{KindLRU} {
+		for _, kind := range allKinds {
 			if !t.Run(testInfo.Name, testInfo.Func(kind)) {
 				return
 			}
@@ -77,6 +133,320 @@ func testCacheLoadFunc(t *testing.T, kind Kind) {
 
 }
 
+func testCacheLoadFuncWithMeta(t *testing.T, kind Kind) {
+
+	conf := &Config{
+		Shards:  2,
+		MaxSize: 100,
+		Kind:    kind,
+		TTL:     time.Hour,
+		Clock:   scachetest.NewFakeClock(time.Now()),
+	}
+
+	var calls int32
+	loadFunc := func(ctx context.Context, key interface{}) (val interface{}, ttl time.Duration, cost int64, err error) {
+		atomic.AddInt32(&calls, 1)
+		switch key {
+		case "short":
+			ttl = 10 * time.Millisecond
+		case "negative":
+			ttl = -time.Second
+		}
+		return key, ttl, 5, nil
+	}
+
+	cache, err := FromConfig(conf).LoaderFuncWithMeta(loadFunc).Build()
+	require.NoError(t, err)
+	defer cache.Close()
+
+	for _, key := range []string{"short", "long"} {
+		val, err := cache.Get(key)
+		require.NoError(t, err)
+		require.Equal(t, key, val)
+	}
+	require.Equal(t, int64(10), cache.Weight())
+
+	conf.Clock.(*scachetest.FakeClock).Advance(20 * time.Millisecond)
+
+	// the value with the short lifetime is loaded again
+	for _, key := range []string{"short", "long"} {
+		val, err := cache.Get(key)
+		require.NoError(t, err)
+		require.Equal(t, key, val)
+	}
+	require.Equal(t, int32(3), atomic.LoadInt32(&calls))
+
+	// the value with the negative lifetime isn't cached
+	for i := 0; i < 2; i++ {
+		val, err := cache.Get("negative")
+		require.NoError(t, err)
+		require.Equal(t, "negative", val)
+	}
+	require.Equal(t, int32(5), atomic.LoadInt32(&calls))
+	require.Equal(t, int64(2), cache.Count())
+	require.Equal(t, int64(10), cache.Weight())
+}
+
+func testCacheRefreshAhead(t *testing.T, kind Kind) {
+
+	conf := &Config{
+		Shards:       2,
+		MaxSize:      100,
+		Kind:         kind,
+		TTL:          100 * time.Millisecond,
+		RefreshAhead: 0.5,
+		Clock:        scachetest.NewFakeClock(time.Now()),
+	}
+
+	var calls int32
+	loadFunc := func(key interface{}) (val interface{}, err error) {
+		return atomic.AddInt32(&calls, 1), nil
+	}
+
+	cache, err := FromConfig(conf).LoaderFunc(loadFunc).Build()
+	require.NoError(t, err)
+	defer cache.Close()
+
+	val, err := cache.Get("key")
+	require.NoError(t, err)
+	require.Equal(t, int32(1), val)
+
+	// the item isn't reloaded in the first half of its lifetime
+	val, err = cache.Get("key")
+	require.NoError(t, err)
+	require.Equal(t, int32(1), val)
+	require.Equal(t, int32(1), atomic.LoadInt32(&calls))
+
+	conf.Clock.(*scachetest.FakeClock).Advance(60 * time.Millisecond)
+
+	// the current value is returned, the item is reloaded in the background
+	val, err = cache.Get("key")
+	require.NoError(t, err)
+	require.Equal(t, int32(1), val)
+
+	require.Eventually(t, func() bool {
+		val, err := cache.Get("key")
+		return err == nil && val == int32(2)
+	}, time.Second, time.Millisecond)
+	require.Equal(t, int32(2), atomic.LoadInt32(&calls))
+}
+
+func testCacheStale(t *testing.T, kind Kind) {
+
+	conf := &Config{
+		Shards:   2,
+		MaxSize:  100,
+		Kind:     kind,
+		TTL:      30 * time.Millisecond,
+		StaleTTL: time.Hour,
+		Clock:    scachetest.NewFakeClock(time.Now()),
+	}
+
+	var calls int32
+	loadFunc := func(key interface{}) (val interface{}, err error) {
+		val = atomic.AddInt32(&calls, 1)
+		if val == int32(2) {
+			val, err = nil, errors.New("failed to upload")
+		}
+		return
+	}
+
+	cache, err := FromConfig(conf).LoaderFunc(loadFunc).Build()
+	require.NoError(t, err)
+	defer cache.Close()
+
+	val, stale, err := cache.GetWithStale(context.Background(), "key")
+	require.NoError(t, err)
+	require.False(t, stale)
+	require.Equal(t, int32(1), val)
+
+	conf.Clock.(*scachetest.FakeClock).Advance(40 * time.Millisecond)
+
+	// the stale value is returned, the reload fails
+	val, stale, err = cache.GetWithStale(context.Background(), "key")
+	require.NoError(t, err)
+	require.True(t, stale)
+	require.Equal(t, int32(1), val)
+
+	require.Eventually(t, func() bool {
+		return atomic.LoadInt32(&calls) == 2 && atomic.LoadInt32(&cache.flights[0].inflight) == 0 &&
+			atomic.LoadInt32(&cache.flights[1].inflight) == 0
+	}, time.Second, time.Millisecond)
+
+	// the stale value is kept after the error
+	val, stale, err = cache.GetWithStale(context.Background(), "key")
+	require.NoError(t, err)
+	require.True(t, stale)
+	require.Equal(t, int32(1), val)
+
+	require.Eventually(t, func() bool {
+		val, stale, err := cache.GetWithStale(context.Background(), "key")
+		return err == nil && !stale && val == int32(3)
+	}, time.Second, time.Millisecond)
+}
+
+func testCacheXFetch(t *testing.T, kind Kind) {
+
+	for _, testInfo := range []struct {
+		Beta   float64
+		Reload bool
+	}{
+		{Beta: 1, Reload: false},  // the load is much shorter than the lifetime
+		{Beta: 1e9, Reload: true}, // the huge beta makes the early expiration certain
+	} {
+		clock := scachetest.NewFakeClock(time.Now())
+		conf := &Config{
+			Shards:     2,
+			MaxSize:    100,
+			Kind:       kind,
+			TTL:        time.Hour,
+			XFetchBeta: testInfo.Beta,
+			Clock:      clock,
+		}
+
+		var calls int32
+		loadFunc := func(key interface{}) (val interface{}, err error) {
+			clock.Advance(time.Minute) // the duration of the load
+			return atomic.AddInt32(&calls, 1), nil
+		}
+
+		cache, err := FromConfig(conf).LoaderFunc(loadFunc).Build()
+		require.NoError(t, err)
+
+		val, err := cache.Get("key")
+		require.NoError(t, err)
+		require.Equal(t, int32(1), val)
+
+		if testInfo.Reload {
+			clock.Advance(time.Hour - time.Second)
+
+			// the current value is returned, the item is reloaded in the background
+			require.Eventually(t, func() bool {
+				val, err := cache.Get("key")
+				require.NoError(t, err)
+				return val.(int32) > 1
+			}, time.Second, time.Millisecond)
+		} else {
+			// the reload is started by Get, so there isn't the load in flight
+			for i := 0; i < 100; i++ {
+				val, err = cache.Get("key")
+				require.NoError(t, err)
+				require.Equal(t, int32(1), val)
+			}
+			for _, f := range cache.flights {
+				require.Equal(t, int32(0), atomic.LoadInt32(&f.inflight))
+			}
+			require.Equal(t, int32(1), atomic.LoadInt32(&calls))
+		}
+
+		cache.Close()
+	}
+}
+
+func testCacheSetExp(t *testing.T, kind Kind) {
+
+	const ttl = time.Minute
+
+	for _, defaultTTL := range []time.Duration{0, ttl} {
+		clock := scachetest.NewFakeClock(time.Now())
+		conf := &Config{
+			Shards:  2,
+			MaxSize: 100,
+			Kind:    kind,
+			TTL:     defaultTTL,
+			Clock:   clock,
+		}
+
+		cache, err := FromConfig(conf).Build()
+		require.NoError(t, err)
+
+		cache.Set("default", "DATA")
+		cache.SetExp("custom", "DATA", ttl)
+		cache.SetExp("long", "DATA", time.Hour)
+		cache.SetExp("no expire", "DATA", NoExpire)
+		cache.SetExpireAt("expire at", "DATA", clock.Now().Add(ttl))
+		cache.Set("passed", "DATA")
+		cache.SetExpireAt("passed", "DATA", clock.Now().Add(-time.Second))
+		cache.Set("negative", "DATA")
+		cache.SetExp("negative", "DATA", -time.Second)
+		cache.SetSliding("negative sliding", "DATA", -time.Second)
+
+		for _, key := range []string{"passed", "negative", "negative sliding"} {
+			_, err = cache.Get(key)
+			require.Equal(t, ErrNotFound, err, key)
+		}
+
+		clock.Advance(2 * ttl)
+
+		for key, expired := range map[string]bool{
+			"default":   defaultTTL > 0,
+			"custom":    true,
+			"long":      false,
+			"no expire": false,
+			"expire at": true,
+		} {
+			val, err := cache.Get(key)
+			if expired {
+				require.Equal(t, ErrNotFound, err, key)
+			} else {
+				require.NoError(t, err, key)
+				require.Equal(t, "DATA", val, key)
+			}
+		}
+
+		cache.Close()
+	}
+}
+
+func testCacheSliding(t *testing.T, kind Kind) {
+
+	const ttl = time.Minute
+
+	for _, sliding := range []bool{false, true} {
+		clock := scachetest.NewFakeClock(time.Now())
+		conf := &Config{
+			Shards:            2,
+			MaxSize:           100,
+			Kind:              kind,
+			TTL:               ttl,
+			SlidingExpiration: sliding,
+			Clock:             clock,
+		}
+
+		cache, err := FromConfig(conf).Build()
+		require.NoError(t, err)
+
+		cache.Set("default", "DATA")
+		cache.SetSliding("sliding", "DATA", ttl)
+
+		// the items are used during 2 lifetimes
+		for i := 0; i < 4; i++ {
+			clock.Advance(ttl / 4)
+			for _, key := range []string{"default", "sliding"} {
+				_, _ = cache.Get(key)
+			}
+		}
+		clock.Advance(ttl / 2)
+
+		_, err = cache.Get("sliding")
+		require.NoError(t, err)
+
+		_, err = cache.Get("default")
+		if sliding {
+			require.NoError(t, err)
+		} else {
+			require.Equal(t, ErrNotFound, err)
+		}
+
+		// the item expires if it isn't used
+		clock.Advance(2 * ttl)
+		_, err = cache.Get("sliding")
+		require.Equal(t, ErrNotFound, err)
+
+		cache.Close()
+	}
+}
+
 func testCacheSetAndGet(t *testing.T, kind Kind) {
 
 	conf := Config{
@@ -101,6 +471,648 @@ func testCacheSetAndGet(t *testing.T, kind Kind) {
 	}
 }
 
+func TestCacheLoadSingleFlight(t *testing.T) {
+
+	var (
+		calls   int32
+		release = make(chan struct{})
+	)
+
+	loadFunc := func(key interface{}) (val interface{}, err error) {
+		atomic.AddInt32(&calls, 1)
+		if key == "slow" {
+			<-release
+		}
+		val = key
+		return
+	}
+
+	cache, err := New(1, 100).LRU().LoaderFunc(loadFunc).Build()
+	require.NoError(t, err)
+	defer cache.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			val, err := cache.Get("slow")
+			require.NoError(t, err)
+			require.Equal(t, "slow", val)
+		}()
+	}
+
+	require.Eventually(t, func() bool {
+		return atomic.LoadInt32(&calls) == 1
+	}, time.Second, time.Millisecond)
+
+	// the slow load doesn't block other keys of the shard
+	cache.Set("hit", 1)
+	{
+		val, err := cache.Get("hit")
+		require.NoError(t, err)
+		require.Equal(t, 1, val)
+	}
+	{
+		val, err := cache.Get("fast")
+		require.NoError(t, err)
+		require.Equal(t, "fast", val)
+	}
+
+	close(release)
+	wg.Wait()
+
+	require.Equal(t, int32(2), atomic.LoadInt32(&calls))
+}
+
+func TestCacheLoadWithConcurrentSetAndDel(t *testing.T) {
+
+	var (
+		started = make(chan struct{}, 1)
+		release = make(chan struct{})
+	)
+
+	loadFunc := func(key interface{}) (val interface{}, err error) {
+		started <- struct{}{}
+		<-release
+		val = "loaded"
+		return
+	}
+
+	cache, err := New(1, 100).LRU().LoaderFunc(loadFunc).Build()
+	require.NoError(t, err)
+	defer cache.Close()
+
+	for _, testInfo := range []struct {
+		Name   string
+		Change func(key string)
+		Exist  bool
+	}{
+		{
+			Name:   "Set",
+			Change: func(key string) { cache.Set(key, "set") },
+			Exist:  true,
+		},
+		{
+			Name:   "Del",
+			Change: func(key string) { cache.Del(key) },
+		},
+	} {
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			val, err := cache.Get(testInfo.Name)
+			require.NoError(t, err)
+			require.Equal(t, "loaded", val) // waiters get the loaded value
+		}()
+
+		<-started
+		testInfo.Change(testInfo.Name)
+		release <- struct{}{}
+		<-done
+
+		// the loaded value isn't stored over the changes which were made during the load
+		val, err := cache.shards[0].Get(testInfo.Name)
+		if testInfo.Exist {
+			require.NoError(t, err, testInfo.Name)
+			require.Equal(t, "set", val, testInfo.Name)
+		} else {
+			require.Equal(t, ErrNotFound, err, testInfo.Name)
+		}
+	}
+}
+
+func TestCacheLoadWithOverflow(t *testing.T) {
+
+	loadFunc := func(key interface{}) (val interface{}, err error) {
+		return key, nil
+	}
+
+	for _, kind := range []Kind{KindLRU, KindLFU} {
+		cache, err := FromConfig(&Config{Kind: kind, Shards: 1, MaxSize: 10}).LoaderFunc(loadFunc).Build()
+		require.NoError(t, err)
+
+		// the cleaner forgets the loads of the evicted keys while the loaded
+		// values are stored, it mustn't wait for the stores and vice versa
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+
+			var wg sync.WaitGroup
+			for g := 0; g < 64; g++ {
+				wg.Add(1)
+				go func(g int) {
+					defer wg.Done()
+					for i := 0; i < 1000; i++ {
+						key := g*1000 + i
+						if i%2 == 0 {
+							cache.Set(key, key)
+						} else {
+							_, _ = cache.Get(key)
+						}
+					}
+				}(g)
+			}
+			wg.Wait()
+		}()
+
+		select {
+		case <-done:
+		case <-time.After(30 * time.Second):
+			require.FailNow(t, "the cache is deadlocked", kind)
+		}
+
+		cache.Close()
+	}
+}
+
+func TestCacheLoadPanic(t *testing.T) {
+
+	release := make(chan struct{})
+	loadFunc := func(key interface{}) (val interface{}, err error) {
+		<-release
+		panic("test")
+	}
+
+	cache, err := New(1, 100).LRU().LoaderFunc(loadFunc).Build()
+	require.NoError(t, err)
+	defer cache.Close()
+
+	done := make(chan error)
+	for i := 0; i < 2; i++ {
+		go func() {
+			_, err := cache.Get("key")
+			done <- err
+		}()
+	}
+
+	require.Eventually(t, func() bool {
+		return atomic.LoadInt32(&cache.flights[0].inflight) == 1
+	}, time.Second, time.Millisecond)
+
+	time.Sleep(50 * time.Millisecond) // the second call waits for the first one
+	close(release)
+
+	for i := 0; i < 2; i++ {
+		err := <-done
+		require.True(t, errors.Is(err, ErrLoaderPanic))
+		require.Contains(t, err.Error(), "test")
+	}
+}
+
+func TestCacheLoadCtx(t *testing.T) {
+
+	release := make(chan struct{})
+	loadFunc := func(ctx context.Context, key interface{}) (val interface{}, err error) {
+		<-release
+		return key, nil
+	}
+
+	cache, err := New(1, 100).LRU().LoaderFuncCtx(loadFunc).Build()
+	require.NoError(t, err)
+	defer cache.Close()
+
+	done := make(chan error)
+	go func() {
+		v, err := cache.GetCtx(context.Background(), "key")
+		if err == nil && v != "key" {
+			err = errors.New("unexpected value")
+		}
+		done <- err
+	}()
+
+	require.Eventually(t, func() bool {
+		return atomic.LoadInt32(&cache.flights[0].inflight) == 1
+	}, time.Second, time.Millisecond)
+
+	// the caller gives up, but the load continues for the other caller
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = cache.GetCtx(ctx, "key")
+	require.Equal(t, context.DeadlineExceeded, err)
+
+	close(release)
+	require.NoError(t, <-done)
+
+	v, err := cache.Get("key")
+	require.NoError(t, err)
+	require.Equal(t, "key", v)
+}
+
+func TestCacheLoadCtxCancel(t *testing.T) {
+
+	cancelled := make(chan struct{})
+	loadFunc := func(ctx context.Context, key interface{}) (val interface{}, err error) {
+		<-ctx.Done()
+		close(cancelled)
+		return nil, ctx.Err()
+	}
+
+	cache, err := New(1, 100).LRU().LoaderFuncCtx(loadFunc).Build()
+	require.NoError(t, err)
+	defer cache.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+
+	_, err = cache.GetCtx(ctx, "key")
+	require.Equal(t, context.Canceled, err)
+
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("the load isn't cancelled")
+	}
+
+	require.Eventually(t, func() bool {
+		return atomic.LoadInt32(&cache.flights[0].inflight) == 0
+	}, time.Second, time.Millisecond)
+}
+
+func TestCacheNegativeCaching(t *testing.T) {
+
+	var (
+		errTemporary = errors.New("temporary")
+		errFatal     = errors.New("fatal")
+		calls        sync.Map
+	)
+
+	loadFunc := func(key interface{}) (val interface{}, err error) {
+		v, _ := calls.LoadOrStore(key, new(int32))
+		atomic.AddInt32(v.(*int32), 1)
+
+		switch key {
+		case "temporary":
+			err = errTemporary
+		case "fatal":
+			err = errFatal
+		case "missing":
+			err = ErrNotFound
+		default:
+			val = key
+		}
+		return
+	}
+
+	callsOf := func(key string) int32 {
+		v, _ := calls.LoadOrStore(key, new(int32))
+		return atomic.LoadInt32(v.(*int32))
+	}
+
+	cache, err := New(1, 100).LRU().
+		LoaderFunc(loadFunc).
+		ErrorTTL(20 * time.Millisecond).
+		NotFoundTTL(time.Hour).
+		CacheableError(func(err error) bool { return err != errTemporary }).
+		Build()
+	require.NoError(t, err)
+	defer cache.Close()
+
+	for i := 0; i < 3; i++ {
+		_, err := cache.Get("temporary")
+		require.Equal(t, errTemporary, err)
+
+		val, err := cache.Get("fatal")
+		require.Equal(t, errFatal, err)
+		require.Nil(t, val)
+
+		_, err = cache.Get("missing")
+		require.Equal(t, ErrNotFound, err)
+	}
+
+	require.Equal(t, int32(3), callsOf("temporary"))
+	require.Equal(t, int32(1), callsOf("fatal"))
+	require.Equal(t, int32(1), callsOf("missing"))
+
+	// the error expires
+	time.Sleep(30 * time.Millisecond)
+	_, err = cache.Get("fatal")
+	require.Equal(t, errFatal, err)
+	require.Equal(t, int32(2), callsOf("fatal"))
+
+	// the cached error is replaced by the value
+	cache.Set("missing", "found")
+	val, err := cache.Get("missing")
+	require.NoError(t, err)
+	require.Equal(t, "found", val)
+}
+
+func TestCacheEviction(t *testing.T) {
+
+	for _, samples := range []int{0, 5} {
+		cache, err := New(1, 10).LRU().ItemsToPrune(2).EvictionSamples(samples).Build()
+		require.NoError(t, err)
+
+		for i := 0; i < 100; i++ {
+			cache.Set(i, i)
+		}
+
+		require.Eventually(t, func() bool {
+			return cache.Count() <= 10
+		}, time.Second, time.Millisecond, samples)
+
+		cache.Close()
+	}
+}
+
+func TestCacheWeight(t *testing.T) {
+
+	weigher := func(key, value interface{}) int64 {
+		return int64(len(value.(string)))
+	}
+
+	for _, kind := range allKinds {
+		cache, err := FromConfig(&Config{
+			Kind:    kind,
+			Shards:  1,
+			MaxSize: 100,
+			Weigher: weigher,
+		}).Build()
+		require.NoError(t, err)
+
+		for i := 0; i < 10; i++ {
+			cache.Set(i, strings.Repeat("a", 30))
+		}
+
+		require.Eventually(t, func() bool {
+			return cache.Weight() <= 100 && cache.Count() == cache.Weight()/30
+		}, time.Second, time.Millisecond, kind)
+
+		cache.SetWithCost("big", "b", 90)
+		require.Eventually(t, func() bool {
+			return cache.Weight() <= 100
+		}, time.Second, time.Millisecond, kind)
+
+		cache.Close()
+	}
+}
+
+func TestCacheExpirationInterval(t *testing.T) {
+
+	const ttl = 20 * time.Minute
+
+	for _, kind := range allKinds {
+		clock := scachetest.NewFakeClock(time.Now())
+
+		// the wheel is advanced by the test, the ticker of the cleaner doesn't fire
+		cache, err := FromConfig(&Config{
+			Kind:               kind,
+			Shards:             4,
+			MaxSize:            1000,
+			TTL:                ttl,
+			ExpirationInterval: time.Minute,
+			Clock:              clock,
+		}).Build()
+		require.NoError(t, err)
+
+		var expiredKeys []interface{}
+
+		for i := 0; i < 100; i++ {
+			cache.Set(i, i)
+		}
+		cache.SetExp("forever", 1, NoExpire)
+		cache.SetSliding("sliding", 1, ttl)
+		cache.Set("deleted", 1)
+		require.True(t, cache.Del("deleted"))
+		require.Equal(t, int64(102), cache.Count(), kind)
+		require.Equal(t, 101, cache.wheel.Len(), kind)
+
+		// the expired items are deleted without Get, the sliding item is used
+		clock.Advance(ttl / 2)
+		_, err = cache.Get("sliding")
+		require.NoError(t, err, kind)
+
+		clock.Advance(ttl/2 + time.Minute)
+		cache.deleteExpired(&expiredKeys)
+		require.Equal(t, int64(2), cache.Count(), kind)
+		require.Equal(t, 1, cache.wheel.Len(), kind)
+
+		clock.Advance(ttl)
+		cache.deleteExpired(&expiredKeys)
+		require.Equal(t, int64(1), cache.Count(), kind)
+		require.Equal(t, 0, cache.wheel.Len(), kind)
+
+		v, err := cache.Get("forever")
+		require.NoError(t, err, kind)
+		require.Equal(t, 1, v, kind)
+
+		cache.Close()
+	}
+}
+
+func TestCacheExpirationIntervalEviction(t *testing.T) {
+
+	for _, kind := range allKinds {
+		cache, err := FromConfig(&Config{
+			Kind:               kind,
+			Shards:             1,
+			MaxSize:            10,
+			TTL:                time.Hour,
+			ExpirationInterval: time.Hour,
+		}).Build()
+		require.NoError(t, err)
+
+		for i := 0; i < 10000; i++ {
+			cache.Set(i, i)
+		}
+
+		// the evicted keys aren't kept in the timing wheel
+		require.Eventually(t, func() bool {
+			return cache.Count() <= 10 && int64(cache.wheel.Len()) <= cache.Count()
+		}, time.Second, time.Millisecond, kind)
+
+		cache.Close()
+	}
+}
+
+func TestCacheOnEvict(t *testing.T) {
+
+	type event struct {
+		Key    interface{}
+		Value  interface{}
+		Reason RemovalReason
+	}
+
+	for _, kind := range allKinds {
+		var (
+			mu     sync.Mutex
+			events []event
+			clock  = scachetest.NewFakeClock(time.Now())
+		)
+
+		cache, err := FromConfig(&Config{
+			Kind:    kind,
+			Shards:  1,
+			MaxSize: 10,
+			Clock:   clock,
+			OnEvict: func(key interface{}, value interface{}, reason RemovalReason) {
+				mu.Lock()
+				events = append(events, event{Key: key, Value: value, Reason: reason})
+				mu.Unlock()
+			},
+		}).Build()
+		require.NoError(t, err)
+
+		cache.Set("replaced", 1)
+		cache.Set("replaced", 2)
+		cache.Set("deleted", 1)
+		require.True(t, cache.Del("deleted"))
+		cache.SetExp("expired", 1, time.Minute)
+		clock.Advance(time.Minute)
+		_, err = cache.Get("expired")
+		require.Equal(t, ErrNotFound, err)
+
+		for i := 0; i < 20; i++ {
+			cache.Set(i, i)
+		}
+		require.Eventually(t, func() bool {
+			return cache.Count() <= 10
+		}, time.Second, time.Millisecond, kind)
+
+		count := cache.Count()
+		cache.Clear()
+		require.Equal(t, int64(0), cache.Count())
+		require.Equal(t, int64(0), cache.Weight())
+
+		cache.Close()
+
+		reasons := make(map[RemovalReason]int)
+		for _, e := range events {
+			reasons[e.Reason]++
+		}
+
+		require.Contains(t, events, event{Key: "replaced", Value: 1, Reason: RemovalReplaced}, kind)
+		require.Contains(t, events, event{Key: "deleted", Value: 1, Reason: RemovalDeleted}, kind)
+		require.Contains(t, events, event{Key: "expired", Value: 1, Reason: RemovalExpired}, kind)
+		require.Equal(t, int(count), reasons[RemovalCleared], kind)
+		require.Equal(t, 21-int(count), reasons[RemovalEvicted], kind)
+	}
+}
+
+func TestCacheSubscribe(t *testing.T) {
+
+	for _, kind := range allKinds {
+		clock := scachetest.NewFakeClock(time.Now())
+
+		loadFunc := func(key interface{}) (val interface{}, err error) {
+			return "loaded", nil
+		}
+
+		cache, err := FromConfig(&Config{
+			Kind:    kind,
+			Shards:  1,
+			MaxSize: 100,
+			Clock:   clock,
+		}).LoaderFunc(loadFunc).Build()
+		require.NoError(t, err)
+
+		events, cancel := cache.Subscribe(0)
+		loads, cancelLoads := cache.Subscribe(EventLoad)
+
+		cache.Set("key", 1)
+		cache.Set("key", 2)
+		require.True(t, cache.Del("key"))
+		cache.SetExp("key", 3, time.Minute)
+		clock.Advance(time.Minute)
+		_, err = cache.Get("key") // the expired item is loaded again
+		require.NoError(t, err)
+		cache.Clear()
+
+		for _, e := range []Event{
+			{Type: EventInsert, Key: "key", Value: 1},
+			{Type: EventUpdate, Key: "key", Value: 2},
+			{Type: EventDelete, Key: "key", Value: 2},
+			{Type: EventInsert, Key: "key", Value: 3},
+			{Type: EventExpire, Key: "key", Value: 3},
+			{Type: EventInsert, Key: "key", Value: "loaded"},
+			{Type: EventLoad, Key: "key", Value: "loaded"},
+			{Type: EventDelete, Key: "key", Value: "loaded"},
+		} {
+			actual := <-events
+			actual.Time = time.Time{}
+			require.Equal(t, e, actual, kind)
+		}
+
+		cancel()
+		_, ok := <-events
+		require.False(t, ok, kind)
+
+		require.Equal(t, Event{Type: EventLoad, Key: "key", Value: "loaded", Time: clock.Now()}, <-loads, kind)
+		require.Equal(t, int64(0), cache.DroppedEvents(), kind)
+
+		cache.Close()
+		_, ok = <-loads
+		require.False(t, ok, kind)
+		cancelLoads()
+	}
+}
+
+func TestCacheStats(t *testing.T) {
+
+	for _, kind := range allKinds {
+		clock := scachetest.NewFakeClock(time.Now())
+
+		loadFunc := func(key interface{}) (val interface{}, err error) {
+			clock.Advance(time.Second)
+			if key == "error" {
+				err = errors.New("failed to upload")
+			}
+			return key, err
+		}
+
+		cache, err := FromConfig(&Config{
+			Kind:    kind,
+			Shards:  2,
+			MaxSize: 100,
+			Clock:   clock,
+		}).LoaderFunc(loadFunc).Build()
+		require.NoError(t, err)
+
+		cache.SetExp("expired", 1, time.Minute)
+		cache.Set("replaced", 1)
+		cache.Set("replaced", 2)
+		cache.Set("deleted", 1)
+		require.True(t, cache.Del("deleted"))
+
+		_, err = cache.Get("loaded") // miss and load
+		require.NoError(t, err)
+		_, err = cache.Get("loaded") // hit
+		require.NoError(t, err)
+		_, err = cache.Get("error") // miss and load
+		require.Error(t, err)
+
+		values, err := cache.GetMulti([]interface{}{"loaded", "replaced"})
+		require.NoError(t, err)
+		require.Len(t, values, 2)
+
+		clock.Advance(time.Minute)
+		_, err = cache.Get("expired") // miss and load
+		require.NoError(t, err)
+
+		require.Equal(t, Stats{
+			Hits:        3,
+			Misses:      3,
+			Loads:       3,
+			LoadErrors:  1,
+			LoadTime:    3 * time.Second,
+			Expirations: 1,
+			Evictions: map[RemovalReason]uint64{
+				RemovalReplaced: 1,
+				RemovalDeleted:  1,
+			},
+		}, cache.Stats(), kind)
+		require.Equal(t, 0.5, cache.Stats().HitRatio(), kind)
+
+		cache.ResetStats()
+		require.Equal(t, Stats{Evictions: map[RemovalReason]uint64{}}, cache.Stats(), kind)
+
+		cache.Close()
+	}
+}
+
 func BenchmarkBaseSCache(b *testing.B) {
 
 	countOverflowKeys := 101
@@ -0,0 +1,17 @@
+package scache
+
+import (
+	"time"
+)
+
+// Clock returns the current time which is used by the expiration of items,
+// e.g. the fake clock of the package scachetest makes tests deterministic.
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
@@ -5,9 +5,95 @@ import (
 )
 
 type Config struct {
-	Kind         Kind
-	TTL          time.Duration
-	MaxSize      int64
+	Kind Kind
+	TTL  time.Duration
+	// MaxSize is the limit of the count of items or of their total weight.
+	// The kinds TinyLFU, ARC, SIEVE, S3FIFO and ExactLRU evict the items of
+	// the shard when the shard is full, every shard gets its part of MaxSize,
+	// so the keys which fall into one shard don't use the whole MaxSize.
+	MaxSize int64
+	// Shards is the count of shards, it can't exceed MaxSize for the kinds
+	// which limit every shard.
 	Shards       int
 	ItemsToPrune uint32
+	// EvictionSamples is the count of the items which are sampled in every
+	// shard to find the oldest items, all items are scanned if it's 0.
+	EvictionSamples int
+	// Weigher returns the weight of the item, MaxSize is the limit of
+	// the total weight if it's set. The weight of the each item is 1 by default.
+	Weigher WeighFunc
+	// ErrorTTL is the lifetime of the errors of the loader function,
+	// the errors aren't cached if it's 0.
+	ErrorTTL time.Duration
+	// NotFoundTTL is the lifetime of the ErrNotFound error of the loader
+	// function, it isn't cached if it's 0.
+	NotFoundTTL time.Duration
+	// CacheableError returns true if the error of the loader function can be
+	// cached, all errors are cached by default. The context errors aren't cached.
+	CacheableError func(err error) bool
+	// BatchWindow is the time during which the misses of Get are collected
+	// to be loaded by one call of the bulk loader function, the misses
+	// aren't collected if it's 0.
+	BatchWindow time.Duration
+	// MaxBatchSize is the max count of keys which are loaded by one call of
+	// the bulk loader function before the end of the window, the count isn't
+	// limited if it's 0.
+	MaxBatchSize int
+	// RefreshAhead is the part of the lifetime of the item, Get returns the item
+	// and reloads it in the background by the loader function if the part of
+	// the lifetime which is left is less. The items aren't reloaded if it's 0.
+	RefreshAhead float64
+	// StaleTTL is the period after the end of the lifetime of the item during
+	// which Get returns the stale item and reloads it in the background by
+	// the loader function. The stale item is returned until the end of the
+	// period if the loader function fails.
+	StaleTTL time.Duration
+	// XFetchBeta enables the probabilistic early expiration: Get reloads the item
+	// in the background before the end of its lifetime with the probability which
+	// grows as the end approaches and with the duration of the load of the item.
+	// The value greater than 1 favors earlier reloads, the items aren't reloaded
+	// early if it's 0.
+	XFetchBeta float64
+	// SlidingExpiration moves the expiration time of the item forward by
+	// its lifetime on every Get, so the item lives while it's used.
+	SlidingExpiration bool
+	// ExpirationInterval is the tick of the timing wheel which deletes the expired
+	// items on schedule, so they don't hold the memory until Get or the eviction.
+	// The expired items are deleted lazily if it's 0.
+	ExpirationInterval time.Duration
+	// Clock returns the current time for the expiration of items,
+	// the system clock is used if it's nil.
+	Clock Clock
+	// OnEvict is called in the background when the item is removed from
+	// the cache by the eviction, the expiration, Del, Set or Clear.
+	OnEvict EvictFunc
+	// EventBuffer is the size of the buffer of the channel of the subscriber
+	// of events, the events are dropped if the buffer is full. It's 1024 if it's 0.
+	EventBuffer int
+}
+
+// clock returns the clock of the expiration of items.
+func (c *Config) clock() Clock {
+	if c.Clock == nil {
+		return systemClock{}
+	}
+	return c.Clock
+}
+
+// shardCapacity returns the part of MaxSize which falls to the shard,
+// the remainder of the division is handed out to the first shards.
+func (c *Config) shardCapacity(shard int) (val int64) {
+
+	if c.Shards > 0 {
+		val = c.MaxSize / int64(c.Shards)
+		if int64(shard) < c.MaxSize%int64(c.Shards) {
+			val++
+		}
+	}
+
+	if val < 1 {
+		val = 1
+	}
+
+	return
 }
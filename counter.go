@@ -4,9 +4,12 @@ import (
 	"sync/atomic"
 )
 
+// counter keeps the count of items and their total weight,
+// the limit is applied to the weight.
 type counter struct {
-	limit int64
-	val   int64
+	limit  int64
+	val    int64
+	weight int64
 }
 
 func newCounter(limit int64) *counter {
@@ -15,8 +18,16 @@ func newCounter(limit int64) *counter {
 	}
 }
 
-func (c *counter) Inc() (overflow bool) {
-	overflow = c.limit < atomic.AddInt64(&c.val, 1)
+// Inc adds the new item.
+func (c *counter) Inc(weight int64) (overflow bool) {
+	atomic.AddInt64(&c.val, 1)
+	overflow = c.limit < atomic.AddInt64(&c.weight, weight)
+	return
+}
+
+// Update changes the weight of the replaced item.
+func (c *counter) Update(delta int64) (overflow bool) {
+	overflow = c.limit < atomic.AddInt64(&c.weight, delta)
 	return
 }
 
@@ -25,10 +36,35 @@ func (c *counter) Limit() (val int64) {
 	return
 }
 
-func (c *counter) Dec() {
+func (c *counter) Dec(weight int64) {
 	atomic.AddInt64(&c.val, -1)
+	atomic.AddInt64(&c.weight, -weight)
 }
 
 func (c *counter) Count() int64 {
 	return atomic.LoadInt64(&c.val)
 }
+
+func (c *counter) Weight() int64 {
+	return atomic.LoadInt64(&c.weight)
+}
+
+func (c *counter) Overflow() bool {
+	return c.limit < atomic.LoadInt64(&c.weight)
+}
+
+// weigh returns the weight of the item: the cost if it's set, otherwise
+// the result of the weigher. The weight is 1 if the weigher isn't set.
+func weigh(weigher WeighFunc, key interface{}, value interface{}, cost int64) (weight int64) {
+
+	weight = cost
+	if weight <= 0 && weigher != nil {
+		weight = weigher(key, value)
+	}
+
+	if weight <= 0 {
+		weight = 1
+	}
+
+	return
+}
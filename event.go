@@ -0,0 +1,243 @@
+package scache
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// EventType is the type of the change of the item, the types
+// are the bit flags, so the filter of events combines them.
+type EventType int
+
+const (
+	// EventInsert is the new item.
+	EventInsert EventType = 1 << iota
+	// EventUpdate is the new value of the existing item.
+	EventUpdate
+	// EventDelete is the removal of the item by Del or Clear.
+	EventDelete
+	// EventEvict is the eviction of the item when the cache is full.
+	EventEvict
+	// EventExpire is the removal of the item after the end of its lifetime.
+	EventExpire
+	// EventLoad is the item which is loaded by the loader function,
+	// it's published after the insert or the update of the item.
+	EventLoad
+
+	// EventAll is the filter of all events.
+	EventAll = EventInsert | EventUpdate | EventDelete | EventEvict | EventExpire | EventLoad
+)
+
+func (t EventType) String() string {
+	switch t {
+	case EventInsert:
+		return "insert"
+	case EventUpdate:
+		return "update"
+	case EventDelete:
+		return "delete"
+	case EventEvict:
+		return "evict"
+	case EventExpire:
+		return "expire"
+	case EventLoad:
+		return "load"
+	}
+	return "unknown"
+}
+
+// Event is the change of the item. The value of the removed item
+// is the value which was removed.
+type Event struct {
+	Type  EventType
+	Key   interface{}
+	Value interface{}
+	Time  time.Time
+}
+
+type subscription struct {
+	ch     chan Event
+	filter EventType
+}
+
+// eventBus publishes the events to the subscribers. The events are sent
+// without waiting, so the event is dropped if the buffer of the subscriber
+// is full.
+type eventBus struct {
+	clock   Clock
+	size    int
+	mu      sync.RWMutex
+	subs    map[*subscription]struct{}
+	active  int32
+	dropped int64
+	closed  bool
+}
+
+func newEventBus(clock Clock, size int) *eventBus {
+	return &eventBus{
+		clock: clock,
+		size:  size,
+		subs:  make(map[*subscription]struct{}),
+	}
+}
+
+// Subscribe returns the channel of the events which match the filter, the cancel
+// function closes the channel. The channel is closed when the bus is closed.
+func (b *eventBus) Subscribe(filter EventType) (<-chan Event, func()) {
+
+	if filter == 0 {
+		filter = EventAll
+	}
+
+	sub := &subscription{
+		ch:     make(chan Event, b.size),
+		filter: filter,
+	}
+
+	b.mu.Lock()
+	if b.closed {
+		close(sub.ch)
+	} else {
+		b.subs[sub] = struct{}{}
+		atomic.AddInt32(&b.active, 1)
+	}
+	b.mu.Unlock()
+
+	var once sync.Once
+	cancel := func() {
+		once.Do(func() {
+			b.mu.Lock()
+			if _, exist := b.subs[sub]; exist {
+				delete(b.subs, sub)
+				atomic.AddInt32(&b.active, -1)
+				close(sub.ch)
+			}
+			b.mu.Unlock()
+		})
+	}
+
+	return sub.ch, cancel
+}
+
+// Publish sends the event to the subscribers, the cached errors
+// of the loader function aren't published.
+func (b *eventBus) Publish(t EventType, key interface{}, value interface{}) {
+
+	if atomic.LoadInt32(&b.active) == 0 {
+		return // there are no subscribers, the lock isn't taken
+	}
+
+	if _, ok := value.(*negativeEntry); ok {
+		return
+	}
+
+	b.mu.RLock()
+	if len(b.subs) > 0 {
+		e := Event{Type: t, Key: key, Value: value, Time: b.clock.Now()}
+		for sub := range b.subs {
+			if sub.filter&t == 0 {
+				continue
+			}
+
+			select {
+			case sub.ch <- e:
+			default:
+				atomic.AddInt64(&b.dropped, 1)
+			}
+		}
+	}
+	b.mu.RUnlock()
+}
+
+// Dropped returns the count of the events which were dropped
+// because the subscribers were slow.
+func (b *eventBus) Dropped() int64 {
+	return atomic.LoadInt64(&b.dropped)
+}
+
+// Close closes the channels of all subscribers.
+func (b *eventBus) Close() {
+
+	b.mu.Lock()
+	b.closed = true
+	for sub := range b.subs {
+		delete(b.subs, sub)
+		close(sub.ch)
+	}
+	atomic.StoreInt32(&b.active, 0)
+	b.mu.Unlock()
+}
+
+// notifier passes the changes of the items of the shard to the subscribers,
+// the eviction callback and the statistics. The events are published under
+// the lock of the shard to keep the order of the changes of the key,
+// the removed items are passed to the callback after the unlock.
+type notifier struct {
+	events    *eventBus
+	evictions *evictQueue
+	stats     *statsCounter
+	// the evicted and expired keys are cancelled in the timing wheel,
+	// the shards remove them without the cache
+	wheel *timingWheel
+}
+
+func newNotifier(events *eventBus, evictions *evictQueue, stats *statsCounter, wheel *timingWheel) *notifier {
+	return &notifier{
+		events:    events,
+		evictions: evictions,
+		stats:     stats,
+		wheel:     wheel,
+	}
+}
+
+// Stored publishes the event of the new value of the key.
+func (n *notifier) Stored(key interface{}, value interface{}, update bool) {
+
+	if n == nil {
+		return
+	}
+
+	if update {
+		n.events.Publish(EventUpdate, key, value)
+	} else {
+		n.events.Publish(EventInsert, key, value)
+	}
+}
+
+// Removed publishes the event of the removed item and appends the item
+// to the list which has to be passed to PushAll after the unlock.
+func (n *notifier) Removed(list []removal, key interface{}, value interface{}, reason RemovalReason) []removal {
+
+	if n == nil {
+		return list
+	}
+
+	n.stats.Removed(reason)
+
+	switch reason {
+	case RemovalEvicted:
+		n.events.Publish(EventEvict, key, value)
+		n.cancel(key)
+	case RemovalExpired:
+		n.events.Publish(EventExpire, key, value)
+		n.cancel(key)
+	case RemovalDeleted, RemovalCleared:
+		n.events.Publish(EventDelete, key, value)
+	}
+
+	return n.evictions.Add(list, key, value, reason)
+}
+
+func (n *notifier) cancel(key interface{}) {
+	if n.wheel != nil {
+		n.wheel.Cancel(key)
+	}
+}
+
+// PushAll passes the removed items to the eviction callback.
+func (n *notifier) PushAll(list []removal) {
+	if n != nil {
+		n.evictions.PushAll(list)
+	}
+}
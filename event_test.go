@@ -0,0 +1,49 @@
+package scache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/khevse/scache/scachetest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventBus(t *testing.T) {
+
+	clock := scachetest.NewFakeClock(time.Now())
+	bus := newEventBus(clock, 2)
+
+	// there aren't subscribers
+	bus.Publish(EventInsert, "key", 1)
+	require.Equal(t, int64(0), bus.Dropped())
+
+	all, cancelAll := bus.Subscribe(0)
+	deleted, cancelDeleted := bus.Subscribe(EventDelete | EventExpire)
+
+	bus.Publish(EventInsert, "key", 1)
+	bus.Publish(EventDelete, "key", 1)
+	bus.Publish(EventInsert, "error", &negativeEntry{})
+	// the buffer of the first subscriber is full
+	bus.Publish(EventExpire, "key", 2)
+	require.Equal(t, int64(1), bus.Dropped())
+
+	require.Equal(t, Event{Type: EventInsert, Key: "key", Value: 1, Time: clock.Now()}, <-all)
+	require.Equal(t, Event{Type: EventDelete, Key: "key", Value: 1, Time: clock.Now()}, <-all)
+	require.Equal(t, Event{Type: EventDelete, Key: "key", Value: 1, Time: clock.Now()}, <-deleted)
+	require.Equal(t, Event{Type: EventExpire, Key: "key", Value: 2, Time: clock.Now()}, <-deleted)
+
+	cancelAll()
+	cancelAll()
+	_, ok := <-all
+	require.False(t, ok)
+
+	bus.Close()
+	_, ok = <-deleted
+	require.False(t, ok)
+	cancelDeleted()
+
+	// the channel of the new subscriber is closed
+	ch, _ := bus.Subscribe(EventAll)
+	_, ok = <-ch
+	require.False(t, ok)
+}
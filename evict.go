@@ -0,0 +1,136 @@
+package scache
+
+import (
+	"context"
+	"sync"
+)
+
+// RemovalReason is the reason of the removal of the item from the cache.
+type RemovalReason int
+
+const (
+	// RemovalEvicted is the eviction of the item when the cache is full.
+	RemovalEvicted RemovalReason = iota + 1
+	// RemovalExpired is the removal of the item after the end of its lifetime.
+	RemovalExpired
+	// RemovalDeleted is the removal of the item by Del.
+	RemovalDeleted
+	// RemovalReplaced is the replacement of the item by the new value of the key.
+	RemovalReplaced
+	// RemovalCleared is the removal of the item by Clear.
+	RemovalCleared
+)
+
+func (r RemovalReason) String() string {
+	switch r {
+	case RemovalEvicted:
+		return "evicted"
+	case RemovalExpired:
+		return "expired"
+	case RemovalDeleted:
+		return "deleted"
+	case RemovalReplaced:
+		return "replaced"
+	case RemovalCleared:
+		return "cleared"
+	}
+	return "unknown"
+}
+
+// EvictFunc is called when the item is removed from the cache, e.g. it closes
+// the resources of the value. It's called in the background in the order
+// of the removals, so it doesn't block the shards and the cleaner.
+type EvictFunc func(key interface{}, value interface{}, reason RemovalReason)
+
+type removal struct {
+	key    interface{}
+	value  interface{}
+	reason RemovalReason
+}
+
+// evictQueue passes the removed items to the eviction callback. The queue isn't
+// limited, so the removals never wait for the callback. The nil queue drops
+// the removed items.
+type evictQueue struct {
+	onEvict EvictFunc
+	mu      sync.Mutex
+	items   []removal
+	chReady chan struct{}
+}
+
+func newEvictQueue(onEvict EvictFunc) *evictQueue {
+
+	if onEvict == nil {
+		return nil
+	}
+
+	return &evictQueue{
+		onEvict: onEvict,
+		chReady: make(chan struct{}, 1),
+	}
+}
+
+// Add appends the removed item to the list if the queue isn't nil,
+// it's used to collect the removals under the lock of the shard.
+func (q *evictQueue) Add(list []removal, key interface{}, value interface{}, reason RemovalReason) []removal {
+	if q == nil {
+		return list
+	}
+	return append(list, removal{key: key, value: value, reason: reason})
+}
+
+// PushAll adds the removed items to the queue. The cached errors
+// of the loader function aren't passed to the callback.
+func (q *evictQueue) PushAll(list []removal) {
+
+	if q == nil || len(list) == 0 {
+		return
+	}
+
+	q.mu.Lock()
+	for _, item := range list {
+		if _, ok := item.value.(*negativeEntry); !ok {
+			q.items = append(q.items, item)
+		}
+	}
+	q.mu.Unlock()
+
+	select {
+	case q.chReady <- struct{}{}:
+	default:
+	}
+}
+
+// run calls the callback for the removed items until the context is done.
+func (q *evictQueue) run(ctx context.Context) {
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-q.chReady:
+			q.flush()
+		}
+	}
+}
+
+func (q *evictQueue) flush() {
+
+	var list []removal
+
+	for {
+		q.mu.Lock()
+		// the list is reused after the callback is called for its items
+		list, q.items = q.items, list[:0]
+		q.mu.Unlock()
+
+		if len(list) == 0 {
+			return
+		}
+
+		for i, item := range list {
+			q.onEvict(item.key, item.value, item.reason)
+			list[i] = removal{}
+		}
+	}
+}
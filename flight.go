@@ -0,0 +1,261 @@
+package scache
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// call is the load of the key which is in flight.
+type call struct {
+	done  chan struct{}
+	value interface{}
+	err   error
+	// the key was set or deleted during the load,
+	// so the loaded value mustn't be stored
+	stale bool
+	// count of the callers which are waiting for the result
+	waiters int
+	cancel  context.CancelFunc
+	// all callers stopped waiting and the load is cancelled,
+	// so the new callers don't join it
+	cancelled bool
+}
+
+// storeFunc stores the loaded value if the function storable returns true.
+type storeFunc func(value interface{}, storable func() bool)
+
+// flightGroup deduplicates the loads of the same key, so callers which
+// are waiting for the same key share the one result.
+type flightGroup struct {
+	ctx      context.Context // the loads are cancelled when the cache is closed
+	mu       sync.Mutex
+	calls    map[interface{}]*call
+	inflight int32
+}
+
+func newFlightGroup(ctx context.Context) *flightGroup {
+	return &flightGroup{
+		ctx:   ctx,
+		calls: make(map[interface{}]*call),
+	}
+}
+
+// Do starts the load if there isn't the load of the key in flight and waits
+// for the result. The loaded value is passed to the store function with
+// the function which returns false if the key was set or deleted during
+// the load, the store function has to call it under the lock of the shard.
+// The caller stops waiting when its context is done, the context of the load
+// is cancelled only when all callers stopped waiting. The load gets the values
+// of the context of the first caller.
+func (g *flightGroup) Do(ctx context.Context, key interface{}, load func(context.Context) (interface{}, error), store storeFunc) (value interface{}, err error) {
+
+	loadCtx, cancel := context.WithCancel(withoutCancel(ctx))
+
+	c, created := g.join(key, cancel)
+	if created {
+		go g.run(loadCtx, key, c, load, store)
+	} else {
+		cancel()
+	}
+
+	return g.wait(ctx, c)
+}
+
+// join adds the caller to the waiters of the load of the key. The new call
+// with the cancel function is created if there isn't the load in flight or
+// it's cancelled, the caller has to finish the new call.
+func (g *flightGroup) join(key interface{}, cancel context.CancelFunc) (c *call, created bool) {
+
+	g.mu.Lock()
+	c, exist := g.calls[key]
+	if exist && c.cancelled {
+		exist = false
+	}
+	if !exist {
+		c = g.add(key, cancel)
+	}
+	c.waiters++
+	g.mu.Unlock()
+
+	return c, !exist
+}
+
+// Go starts the load of the key in the background if there isn't the load
+// of the key in flight. The load isn't cancelled by the callers which join it.
+func (g *flightGroup) Go(key interface{}, load func(context.Context) (interface{}, error), store storeFunc) {
+
+	g.mu.Lock()
+	if _, exist := g.calls[key]; exist {
+		g.mu.Unlock()
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c := g.add(key, cancel)
+	c.waiters++ // the load has the waiter which never stops waiting
+	g.mu.Unlock()
+
+	go g.run(ctx, key, c, load, store)
+}
+
+// add creates the call of the key, it must be called under the lock.
+func (g *flightGroup) add(key interface{}, cancel context.CancelFunc) (c *call) {
+
+	c = &call{
+		done:   make(chan struct{}),
+		cancel: cancel,
+	}
+	g.calls[key] = c
+	atomic.AddInt32(&g.inflight, 1)
+
+	return
+}
+
+// wait waits for the result of the call, the load is cancelled
+// if the last waiter stops waiting.
+func (g *flightGroup) wait(ctx context.Context, c *call) (value interface{}, err error) {
+
+	select {
+	case <-c.done:
+		value, err = c.value, c.err
+
+	case <-ctx.Done():
+		g.mu.Lock()
+		c.waiters--
+		if c.waiters == 0 {
+			c.cancelled = true
+			c.cancel()
+		}
+		g.mu.Unlock()
+
+		err = ctx.Err()
+	}
+
+	return
+}
+
+func (g *flightGroup) run(ctx context.Context, key interface{}, c *call, load func(context.Context) (interface{}, error), store storeFunc) {
+
+	stop := afterFunc(g.ctx, c.cancel)
+
+	defer func() {
+		if r := recover(); r != nil {
+			c.value, c.err = nil, fmt.Errorf("%w: %v", ErrLoaderPanic, r)
+		}
+
+		stop()
+		c.cancel()
+		g.finish(key, c, store)
+	}()
+
+	c.value, c.err = load(ctx)
+}
+
+func (g *flightGroup) finish(key interface{}, c *call, store storeFunc) {
+
+	// the value is stored without the lock of the group, so the cleaner which
+	// forgets the loads doesn't wait for the shards. The call is removed after
+	// the store, so Forget marks it stale until the value is stored.
+	if c.err == nil {
+		store(c.value, func() bool { return g.storable(c) })
+	}
+
+	g.mu.Lock()
+	if g.calls[key] == c {
+		delete(g.calls, key)
+	}
+	atomic.AddInt32(&g.inflight, -1)
+	g.mu.Unlock()
+
+	close(c.done)
+}
+
+// storable returns true if the key wasn't set or deleted during the load
+// and the load wasn't cancelled.
+func (g *flightGroup) storable(c *call) (ok bool) {
+	g.mu.Lock()
+	ok = !c.stale && !c.cancelled
+	g.mu.Unlock()
+	return
+}
+
+// Forget marks the load of the key as stale. It has to be called
+// before the key is set or deleted.
+func (g *flightGroup) Forget(key interface{}) {
+
+	if atomic.LoadInt32(&g.inflight) == 0 {
+		return // there are no loads to mark, the lock isn't taken
+	}
+
+	g.mu.Lock()
+	if c, exist := g.calls[key]; exist {
+		c.stale = true
+	}
+	g.mu.Unlock()
+}
+
+// ForgetAll marks the loads of all keys as stale.
+func (g *flightGroup) ForgetAll() {
+
+	if atomic.LoadInt32(&g.inflight) == 0 {
+		return
+	}
+
+	g.mu.Lock()
+	for _, c := range g.calls {
+		c.stale = true
+	}
+	g.mu.Unlock()
+}
+
+// detachedContext keeps the values of the parent context,
+// but it isn't cancelled when the parent is cancelled.
+type detachedContext struct {
+	parent context.Context
+}
+
+// withoutCancel returns the context which isn't cancelled with the parent,
+// it's context.WithoutCancel which was added in Go 1.21.
+func withoutCancel(parent context.Context) context.Context {
+	return detachedContext{parent: parent}
+}
+
+func (detachedContext) Deadline() (deadline time.Time, ok bool) {
+	return
+}
+
+func (detachedContext) Done() <-chan struct{} {
+	return nil
+}
+
+func (detachedContext) Err() error {
+	return nil
+}
+
+func (c detachedContext) Value(key interface{}) interface{} {
+	return c.parent.Value(key)
+}
+
+// afterFunc calls the function in the background when the context is done,
+// the returned function stops the waiting for the context. It's context.AfterFunc
+// which was added in Go 1.21.
+func afterFunc(ctx context.Context, f func()) (stop func()) {
+
+	chStop := make(chan struct{})
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			f()
+		case <-chStop:
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(chStop) })
+	}
+}
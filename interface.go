@@ -1,15 +1,37 @@
 package scache
 
-import "time"
+import (
+	"context"
+	"time"
+)
 
 type ICache interface {
 	// Set value with default lifetime(optional)
 	Set(key interface{}, value interface{})
 	// Set value with custom lifetime time
 	SetExp(key interface{}, value interface{}, ttl time.Duration)
+	// Set value which expires at the time
+	SetExpireAt(key interface{}, value interface{}, at time.Time)
+	// Set value with custom lifetime time which is moved forward by every Get
+	SetSliding(key interface{}, value interface{}, ttl time.Duration)
+	// Set value with custom weight
+	SetWithCost(key interface{}, value interface{}, cost int64)
 	Get(key interface{}) (value interface{}, err error)
+	// Get value, the context is passed to the loader function
+	GetCtx(ctx context.Context, key interface{}) (value interface{}, err error)
+	// Get value with the flag of the stale value
+	GetWithStale(ctx context.Context, key interface{}) (value interface{}, stale bool, err error)
+	// Get values of the keys, the missing keys are loaded by one call
+	GetMulti(keys []interface{}) (values map[interface{}]interface{}, err error)
+	GetMultiCtx(ctx context.Context, keys []interface{}) (values map[interface{}]interface{}, err error)
 	Del(key interface{}) bool
+	// Delete all keys
+	Clear()
 	Count() int64
+	// Get the statistics of the cache
+	Stats() Stats
+	// Subscribe to the changes of items
+	Subscribe(filter EventType) (<-chan Event, func())
 	Close()
 }
 
@@ -18,12 +40,49 @@ type iShard interface {
 	Set(key interface{}, value interface{})
 	// Set value with custom lifetime time
 	SetExp(key interface{}, value interface{}, ttl time.Duration)
+	// Set value with custom weight
+	SetWithCost(key interface{}, value interface{}, cost int64)
+	// Set value with the options if the key doesn't exist or it's expired
+	Add(key interface{}, value interface{}, opts itemOptions) bool
+	// Set value with the options
+	Replace(key interface{}, value interface{}, opts itemOptions) bool
 	Get(key interface{}) (value interface{}, err error)
+	// Get value with its expiration time and lifetime
+	GetWithMeta(key interface{}) (value interface{}, meta itemMeta, err error)
 	Del(key interface{}) bool
+	// Delete the key with the reason which is passed to the eviction callback
+	Remove(key interface{}, reason RemovalReason) bool
+	// Delete the key if it's expired, the expiration time of the key which isn't expired is returned
+	DelExpired(key interface{}) (expire int64, ok bool)
+	// Delete all keys
+	Clear()
 	Count() int64
 	GetForRemove(expiredKeys *[]interface{}, oldest iListWithOldEntries)
 }
 
+// itemOptions are the options of the item which is set.
+type itemOptions struct {
+	TTL     time.Duration // the default lifetime is used if it's 0
+	Cost    int64         // the result of the weigher is used if it's 0
+	Delta   time.Duration // duration of the load of the item
+	Sliding bool          // the item has the sliding expiration regardless of the default
+	// Storable is called under the lock of the shard, the item isn't stored
+	// if it returns false, e.g. the key was set during the load of the item
+	Storable func() bool
+}
+
+// storable returns true if the item can be stored.
+func (o itemOptions) storable() bool {
+	return o.Storable == nil || o.Storable()
+}
+
+// itemMeta is the metadata of the item which is used to reload it in advance.
+type itemMeta struct {
+	Expire int64         // 0 if the item doesn't expire
+	TTL    time.Duration // lifetime of the item when it was set
+	Delta  time.Duration // duration of the load of the item
+}
+
 type iListWithOldEntries interface {
 	Add(key interface{}, itemCost *uint32, maxCost uint32)
 }
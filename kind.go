@@ -5,4 +5,10 @@ type Kind int
 const (
 	KindUnknown Kind = iota
 	KindLRU
+	KindLFU
+	KindTinyLFU
+	KindARC
+	KindSIEVE
+	KindS3FIFO
+	KindExactLRU
 )
@@ -0,0 +1,113 @@
+package scache
+
+import "time"
+
+// listEntry is an item of the shards which keep the order of their items
+// in the intrusive doubly linked lists.
+type listEntry struct {
+	prev, next *listEntry
+	list       *list
+	hash       uint64 // hash of the key, it is set by the policies which need it
+	freq       uint32 // access counter of the FIFO policies, it is changed atomically
+
+	Key     interface{}
+	Value   interface{}
+	Expire  int64 // it's changed atomically if the expiration is sliding
+	TTL     time.Duration
+	Delta   time.Duration
+	Sliding bool
+	Weight  int64
+}
+
+// SetWeight changes the weight of the entry and the total weight of its list.
+func (e *listEntry) SetWeight(weight int64) {
+	if e.list != nil {
+		e.list.weight += weight - e.Weight
+	}
+	e.Weight = weight
+}
+
+// list is an intrusive doubly linked list. The front of the list is
+// the most recently added entry.
+type list struct {
+	root   listEntry
+	len    int
+	weight int64
+}
+
+func newList() *list {
+	l := &list{}
+	l.root.next = &l.root
+	l.root.prev = &l.root
+	return l
+}
+
+func (l *list) Len() int {
+	return l.len
+}
+
+// Weight returns the total weight of entries.
+func (l *list) Weight() int64 {
+	return l.weight
+}
+
+func (l *list) Front() (e *listEntry) {
+	if l.len > 0 {
+		e = l.root.next
+	}
+	return
+}
+
+func (l *list) Back() (e *listEntry) {
+	if l.len > 0 {
+		e = l.root.prev
+	}
+	return
+}
+
+// Prev returns the entry which is closer to the front of the list.
+func (l *list) Prev(e *listEntry) (prev *listEntry) {
+	if e.list == l && e.prev != &l.root {
+		prev = e.prev
+	}
+	return
+}
+
+func (l *list) PushFront(e *listEntry) {
+	l.insertAfter(e, &l.root)
+}
+
+func (l *list) MoveToFront(e *listEntry) {
+	if e.list != l || l.root.next == e {
+		return
+	}
+
+	l.unlink(e)
+	l.insertAfter(e, &l.root)
+}
+
+func (l *list) Remove(e *listEntry) {
+	if e.list == l {
+		l.unlink(e)
+		e.list = nil
+	}
+}
+
+func (l *list) insertAfter(e, at *listEntry) {
+	e.prev = at
+	e.next = at.next
+	e.prev.next = e
+	e.next.prev = e
+	e.list = l
+	l.len++
+	l.weight += e.Weight
+}
+
+func (l *list) unlink(e *listEntry) {
+	e.prev.next = e.next
+	e.next.prev = e.prev
+	e.next = nil
+	e.prev = nil
+	l.len--
+	l.weight -= e.Weight
+}
@@ -0,0 +1,178 @@
+package scache
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const metricsContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+var (
+	metricNameRegexp = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+	labelNameRegexp  = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+	// the labels of the samples of the metrics
+	reservedLabelNames = map[string]struct{}{"reason": {}, "shard": {}}
+)
+
+// metricsHandler renders the metrics of the cache in the Prometheus text format.
+type metricsHandler struct {
+	cache  *Cache
+	prefix string
+	labels string // the constant labels, e.g. `cache="users"`
+}
+
+// MetricsHandler returns the handler which renders the statistics, the sizes of
+// the shards, the lag of the cleaner and the limits of the cache in the Prometheus
+// text format. The names of the metrics start with the prefix ("scache" if it's
+// empty) and the metrics have the constant labels, e.g. the name of the cache.
+// The error is returned if the prefix or the names of the labels aren't valid
+// in Prometheus or the labels are reserved ("reason" and "shard").
+func (c *Cache) MetricsHandler(prefix string, labels map[string]string) (http.Handler, error) {
+
+	if prefix == "" {
+		prefix = "scache"
+	}
+
+	if !metricNameRegexp.MatchString(prefix) {
+		return nil, errors.New("invalid metrics prefix")
+	}
+
+	for name := range labels {
+		if !labelNameRegexp.MatchString(name) || strings.HasPrefix(name, "__") {
+			return nil, errors.New("invalid metrics label name")
+		}
+
+		if _, exist := reservedLabelNames[name]; exist {
+			return nil, errors.New("reserved metrics label name")
+		}
+	}
+
+	return &metricsHandler{
+		cache:  c,
+		prefix: prefix + "_",
+		labels: formatLabels(labels),
+	}, nil
+}
+
+func (h *metricsHandler) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+
+	var buf bytes.Buffer
+	h.write(&buf)
+
+	w.Header().Set("Content-Type", metricsContentType)
+	_, _ = w.Write(buf.Bytes())
+}
+
+type metricSample struct {
+	labels string
+	value  float64
+}
+
+func (h *metricsHandler) write(buf *bytes.Buffer) {
+
+	c := h.cache
+	stats := c.Stats()
+
+	h.metric(buf, "hits_total", "counter", "The count of the keys which were found.",
+		metricSample{value: float64(stats.Hits)})
+	h.metric(buf, "misses_total", "counter", "The count of the keys which weren't found.",
+		metricSample{value: float64(stats.Misses)})
+	h.metric(buf, "loads_total", "counter", "The count of the calls of the loader functions.",
+		metricSample{value: float64(stats.Loads)})
+	h.metric(buf, "load_errors_total", "counter", "The count of the calls of the loader functions which failed.",
+		metricSample{value: float64(stats.LoadErrors)})
+	h.metric(buf, "load_duration_seconds_total", "counter", "The total duration of the calls of the loader functions.",
+		metricSample{value: stats.LoadTime.Seconds()})
+	h.metric(buf, "expirations_total", "counter", "The count of the items which were removed after the end of their lifetime.",
+		metricSample{value: float64(stats.Expirations)})
+
+	evictions := make([]metricSample, 0, 4)
+	for _, reason := range []RemovalReason{RemovalEvicted, RemovalDeleted, RemovalReplaced, RemovalCleared} {
+		evictions = append(evictions, metricSample{
+			labels: formatLabels(map[string]string{"reason": reason.String()}),
+			value:  float64(stats.Evictions[reason]),
+		})
+	}
+	h.metric(buf, "evictions_total", "counter", "The count of the removed items by the reason.", evictions...)
+
+	h.metric(buf, "dropped_events_total", "counter", "The count of the events which were dropped because the subscribers were slow.",
+		metricSample{value: float64(c.DroppedEvents())})
+
+	shards := make([]metricSample, 0, len(c.shards))
+	for i, s := range c.shards {
+		shards = append(shards, metricSample{
+			labels: formatLabels(map[string]string{"shard": strconv.Itoa(i)}),
+			value:  float64(s.Count()),
+		})
+	}
+	h.metric(buf, "shard_items", "gauge", "The count of items of the shard.", shards...)
+
+	h.metric(buf, "items", "gauge", "The count of items.",
+		metricSample{value: float64(c.Count())})
+	h.metric(buf, "weight", "gauge", "The total weight of items.",
+		metricSample{value: float64(c.Weight())})
+	h.metric(buf, "max_weight", "gauge", "The limit of the total weight of items.",
+		metricSample{value: float64(c.counter.Limit())})
+	h.metric(buf, "shards", "gauge", "The count of shards.",
+		metricSample{value: float64(len(c.shards))})
+	h.metric(buf, "ttl_seconds", "gauge", "The default lifetime of items, 0 if items don't expire.",
+		metricSample{value: c.ttl.Seconds()})
+
+	h.metric(buf, "cleaner_pending", "gauge", "The count of the overflows which the cleaner hasn't processed yet.",
+		metricSample{value: float64(len(c.chClean))})
+
+	if c.wheel != nil {
+		h.metric(buf, "expiration_lag_seconds", "gauge", "The time by which the expiration of items is behind the clock.",
+			metricSample{value: c.wheel.Lag(timeNowLRU(c.clock, 0)).Seconds()})
+	}
+}
+
+// metric writes the samples of the metric, the constant labels are added to the labels of the samples.
+func (h *metricsHandler) metric(buf *bytes.Buffer, name string, kind string, help string, samples ...metricSample) {
+
+	name = h.prefix + name
+
+	buf.WriteString("# HELP " + name + " " + help + "\n")
+	buf.WriteString("# TYPE " + name + " " + kind + "\n")
+
+	for _, sample := range samples {
+		buf.WriteString(name)
+
+		labels := h.labels
+		if sample.labels != "" {
+			if labels != "" {
+				labels += ","
+			}
+			labels += sample.labels
+		}
+		if labels != "" {
+			buf.WriteString("{" + labels + "}")
+		}
+
+		buf.WriteString(" " + strconv.FormatFloat(sample.value, 'g', -1, 64) + "\n")
+	}
+}
+
+var labelValueReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// formatLabels returns the labels which are sorted by the names, e.g. `a="1",b="2"`.
+func formatLabels(labels map[string]string) string {
+
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	pairs := make([]string, 0, len(names))
+	for _, name := range names {
+		pairs = append(pairs, name+`="`+labelValueReplacer.Replace(labels[name])+`"`)
+	}
+
+	return strings.Join(pairs, ",")
+}
@@ -0,0 +1,99 @@
+package scache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetricsHandler(t *testing.T) {
+
+	loadFunc := func(key interface{}) (val interface{}, err error) {
+		return key, nil
+	}
+
+	cache, err := New(2, 100).LRU().TTL(time.Minute).LoaderFunc(loadFunc).Build()
+	require.NoError(t, err)
+	defer cache.Close()
+
+	cache.Set(0, 1)
+	cache.Set(0, 2)
+	_, err = cache.Get(1)
+	require.NoError(t, err)
+	_, err = cache.Get(1)
+	require.NoError(t, err)
+
+	handler, err := cache.MetricsHandler("app_cache", map[string]string{"cache": "users", "env": `"prod"`})
+	require.NoError(t, err)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, metricsContentType, rec.Header().Get("Content-Type"))
+
+	body := rec.Body.String()
+	for _, line := range []string{
+		"# HELP app_cache_hits_total The count of the keys which were found.\n",
+		"# TYPE app_cache_hits_total counter\n",
+		`app_cache_hits_total{cache="users",env="\"prod\""} 1` + "\n",
+		`app_cache_misses_total{cache="users",env="\"prod\""} 1` + "\n",
+		`app_cache_loads_total{cache="users",env="\"prod\""} 1` + "\n",
+		`app_cache_evictions_total{cache="users",env="\"prod\"",reason="replaced"} 1` + "\n",
+		`app_cache_evictions_total{cache="users",env="\"prod\"",reason="evicted"} 0` + "\n",
+		`app_cache_shard_items{cache="users",env="\"prod\"",shard="0"} 1` + "\n",
+		`app_cache_shard_items{cache="users",env="\"prod\"",shard="1"} 1` + "\n",
+		`app_cache_items{cache="users",env="\"prod\""} 2` + "\n",
+		`app_cache_max_weight{cache="users",env="\"prod\""} 100` + "\n",
+		`app_cache_shards{cache="users",env="\"prod\""} 2` + "\n",
+		`app_cache_ttl_seconds{cache="users",env="\"prod\""} 60` + "\n",
+		`app_cache_cleaner_pending{cache="users",env="\"prod\""} 0` + "\n",
+	} {
+		require.Contains(t, body, line)
+	}
+	require.NotContains(t, body, "expiration_lag_seconds")
+
+	// the default prefix without labels
+	handler, err = cache.MetricsHandler("", nil)
+	require.NoError(t, err)
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Contains(t, rec.Body.String(), "\nscache_items 2\n")
+}
+
+func TestMetricsHandlerInvalidNames(t *testing.T) {
+
+	cache, err := New(1, 100).LRU().Build()
+	require.NoError(t, err)
+	defer cache.Close()
+
+	for _, testInfo := range []struct {
+		Prefix string
+		Labels map[string]string
+		Err    string
+	}{
+		{Prefix: "app:cache_1", Labels: map[string]string{"_cache": "users", "env2": "prod"}},
+		{Prefix: "1cache", Err: "invalid metrics prefix"},
+		{Prefix: "app-cache", Err: "invalid metrics prefix"},
+		{Prefix: "app cache", Err: "invalid metrics prefix"},
+		{Labels: map[string]string{"cache-name": "users"}, Err: "invalid metrics label name"},
+		{Labels: map[string]string{"1cache": "users"}, Err: "invalid metrics label name"},
+		{Labels: map[string]string{"app:cache": "users"}, Err: "invalid metrics label name"},
+		{Labels: map[string]string{"": "users"}, Err: "invalid metrics label name"},
+		{Labels: map[string]string{"__name__": "users"}, Err: "invalid metrics label name"},
+		{Labels: map[string]string{"reason": "users"}, Err: "reserved metrics label name"},
+		{Labels: map[string]string{"shard": "1"}, Err: "reserved metrics label name"},
+	} {
+		handler, err := cache.MetricsHandler(testInfo.Prefix, testInfo.Labels)
+		if testInfo.Err == "" {
+			require.NoError(t, err, testInfo)
+			require.NotNil(t, handler, testInfo)
+		} else {
+			require.EqualError(t, err, testInfo.Err, testInfo)
+			require.Nil(t, handler, testInfo)
+		}
+	}
+}
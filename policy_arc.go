@@ -0,0 +1,133 @@
+package scache
+
+// policyARC is the Adaptive Replacement Cache policy. The entries which were
+// used once are kept in t1, the entries which were used at least twice are
+// kept in t2. The keys of the evicted entries are remembered in the ghost
+// lists b1 and b2, and hits in the ghost lists move the target size of t1.
+// Sizes of the lists are the weights of their entries.
+type policyARC struct {
+	capacity int64
+	p        int64 // target size of t1
+	t1, t2   *list
+	b1, b2   *list
+	ghosts   map[interface{}]*listEntry
+	fresh    *listEntry // the last inserted entry
+	hitB2    bool       // the last inserted key was found in b2
+}
+
+func newPolicyARC(capacity int64) *policyARC {
+	return &policyARC{
+		capacity: capacity,
+		t1:       newList(),
+		t2:       newList(),
+		b1:       newList(),
+		b2:       newList(),
+		ghosts:   make(map[interface{}]*listEntry),
+	}
+}
+
+func (a *policyARC) Access(e *listEntry) {
+
+	switch e.list {
+	case a.t1:
+		a.t1.Remove(e)
+		a.t2.PushFront(e)
+	case a.t2:
+		a.t2.MoveToFront(e)
+	}
+}
+
+func (a *policyARC) Insert(e *listEntry) {
+
+	a.fresh = e
+	a.hitB2 = false
+
+	ghost, exist := a.ghosts[e.Key]
+	if !exist {
+		a.t1.PushFront(e)
+		a.trimGhosts()
+		return
+	}
+
+	switch ghost.list {
+	case a.b1:
+		a.p = minInt64(a.p+maxInt64(a.b2.Weight()/a.b1.Weight(), 1)*ghost.Weight, a.capacity)
+	case a.b2:
+		a.p = maxInt64(a.p-maxInt64(a.b1.Weight()/a.b2.Weight(), 1)*ghost.Weight, 0)
+		a.hitB2 = true
+	}
+
+	a.removeGhost(ghost)
+	a.t2.PushFront(e)
+}
+
+func (a *policyARC) Remove(e *listEntry) {
+
+	if e.list == a.t1 || e.list == a.t2 {
+		e.list.Remove(e)
+	}
+
+	if a.fresh == e {
+		a.fresh = nil
+	}
+}
+
+func (a *policyARC) Evict() (e *listEntry) {
+
+	t1Size := a.t1.Weight()
+	if a.fresh != nil && a.fresh.list == a.t1 {
+		// the new entry isn't the part of t1 for the replacement
+		t1Size -= a.fresh.Weight
+	}
+
+	var ghosts *list
+	if t1Size > 0 && (t1Size > a.p || (a.hitB2 && t1Size == a.p)) || a.t2.Len() == 0 {
+		e, ghosts = a.t1.Back(), a.b1
+	} else {
+		e, ghosts = a.t2.Back(), a.b2
+	}
+
+	if e == nil {
+		return
+	}
+
+	a.Remove(e)
+
+	ghost := &listEntry{Key: e.Key, Weight: e.Weight}
+	ghosts.PushFront(ghost)
+	a.ghosts[ghost.Key] = ghost
+	a.trimGhosts()
+
+	return
+}
+
+// trimGhosts keeps |t1|+|b1| <= c and |t1|+|t2|+|b1|+|b2| <= 2c.
+func (a *policyARC) trimGhosts() {
+
+	for a.b1.Len() > 0 && a.t1.Weight()+a.b1.Weight() > a.capacity {
+		a.removeGhost(a.b1.Back())
+	}
+
+	for a.b2.Len() > 0 && a.t1.Weight()+a.t2.Weight()+a.b1.Weight()+a.b2.Weight() > 2*a.capacity {
+		a.removeGhost(a.b2.Back())
+	}
+}
+
+func (a *policyARC) removeGhost(ghost *listEntry) {
+	ghost.list.Remove(ghost)
+	delete(a.ghosts, ghost.Key)
+}
+
+func minInt64(a, b int64) int64 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxInt64(a, b int64) int64 {
+	if a > b {
+		return a
+	}
+	return b
+}
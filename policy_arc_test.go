@@ -0,0 +1,67 @@
+package scache
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestARCScanResistance(t *testing.T) {
+
+	const Capacity = 4
+
+	policy := newPolicyARC(Capacity)
+	cache := newShardList(newCounter(Capacity), nil, &Config{Shards: 1, MaxSize: Capacity}, Capacity, policy)
+
+	for _, k := range []string{"a", "b"} {
+		cache.Set(k, k)
+		_, err := cache.Get(k)
+		require.NoError(t, err)
+	}
+	require.Equal(t, 2, policy.t2.Len())
+
+	for i := 0; i < 10; i++ {
+		cache.Set(strconv.Itoa(i), i)
+		require.LessOrEqual(t, cache.Count(), int64(Capacity))
+	}
+
+	for _, k := range []string{"a", "b"} {
+		val, err := cache.Get(k)
+		require.NoError(t, err)
+		require.Equal(t, k, val)
+	}
+
+	require.Equal(t, int64(0), policy.p)
+	require.Equal(t, Capacity-policy.t1.Len(), policy.b1.Len())
+	require.Equal(t, 0, policy.b2.Len())
+
+	// hit in the ghost list b1 grows the target size of t1
+	_, exist := policy.ghosts["7"]
+	require.True(t, exist)
+	cache.Set("7", 7)
+	require.Equal(t, int64(1), policy.p)
+	require.Equal(t, policy.t2, cache.payload["7"].list)
+	require.LessOrEqual(t, cache.Count(), int64(Capacity))
+}
+
+func TestARCGhostB2(t *testing.T) {
+
+	policy := newPolicyARC(2)
+	policy.p = 1
+
+	a, b := &listEntry{Key: "a", Weight: 1}, &listEntry{Key: "b", Weight: 1}
+	policy.Insert(a)
+	policy.Access(a)
+	policy.Insert(b)
+	policy.Access(b)
+
+	require.Equal(t, a, policy.Evict())
+	require.Equal(t, policy.b2, policy.ghosts["a"].list)
+
+	policy.Insert(&listEntry{Key: "a", Weight: 1})
+	require.True(t, policy.hitB2)
+	require.Equal(t, int64(0), policy.p)
+	require.Equal(t, 0, policy.b2.Len())
+	require.Equal(t, 2, policy.t2.Len())
+}
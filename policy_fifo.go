@@ -0,0 +1,198 @@
+package scache
+
+import (
+	"sync/atomic"
+)
+
+const (
+	s3fifoSmallPercent = 10
+	s3fifoMaxFreq      = 3
+)
+
+// policySIEVE keeps entries in the FIFO order, the hit only sets the visited
+// bit of the entry. The hand moves from the oldest entry to the newest one,
+// resets visited bits and evicts the first entry which wasn't visited.
+type policySIEVE struct {
+	queue *list
+	hand  *listEntry
+	fresh *listEntry // the last inserted entry
+}
+
+func newPolicySIEVE() *policySIEVE {
+	return &policySIEVE{
+		queue: newList(),
+	}
+}
+
+func (p *policySIEVE) SharedAccess() {}
+
+func (p *policySIEVE) Access(e *listEntry) {
+	if atomic.LoadUint32(&e.freq) == 0 {
+		atomic.StoreUint32(&e.freq, 1)
+	}
+}
+
+func (p *policySIEVE) Insert(e *listEntry) {
+	p.queue.PushFront(e)
+	p.fresh = e
+}
+
+func (p *policySIEVE) Remove(e *listEntry) {
+
+	if p.hand == e {
+		p.hand = p.queue.Prev(e)
+	}
+
+	if p.fresh == e {
+		p.fresh = nil
+	}
+
+	p.queue.Remove(e)
+}
+
+func (p *policySIEVE) Evict() (e *listEntry) {
+
+	if p.queue.Len() <= 1 {
+		e = p.queue.Back()
+	} else {
+		e = p.hand
+		for {
+			if e == nil {
+				e = p.queue.Back()
+			}
+
+			if e != p.fresh {
+				if atomic.LoadUint32(&e.freq) == 0 {
+					break
+				}
+				atomic.StoreUint32(&e.freq, 0)
+			}
+
+			e = p.queue.Prev(e)
+		}
+		p.hand = e
+	}
+
+	if e != nil {
+		p.Remove(e)
+	}
+
+	return
+}
+
+// policyS3FIFO keeps new entries in the small FIFO queue, the entries
+// which were hit in the small queue are moved to the main FIFO queue,
+// others are evicted and their keys are remembered in the ghost queue.
+// The entries of the main queue are reinserted while they have hits.
+type policyS3FIFO struct {
+	small    *list
+	main     *list
+	ghost    *list
+	ghosts   map[interface{}]*listEntry
+	smallCap int64
+	ghostCap int64
+}
+
+func newPolicyS3FIFO(capacity int64) *policyS3FIFO {
+
+	smallCap := capacity * s3fifoSmallPercent / 100
+	if smallCap < 1 {
+		smallCap = 1
+	}
+
+	ghostCap := capacity - smallCap
+	if ghostCap < 1 {
+		ghostCap = 1
+	}
+
+	return &policyS3FIFO{
+		small:    newList(),
+		main:     newList(),
+		ghost:    newList(),
+		ghosts:   make(map[interface{}]*listEntry),
+		smallCap: smallCap,
+		ghostCap: ghostCap,
+	}
+}
+
+func (p *policyS3FIFO) SharedAccess() {}
+
+func (p *policyS3FIFO) Access(e *listEntry) {
+	if freq := atomic.LoadUint32(&e.freq); freq < s3fifoMaxFreq {
+		atomic.CompareAndSwapUint32(&e.freq, freq, freq+1)
+	}
+}
+
+func (p *policyS3FIFO) Insert(e *listEntry) {
+
+	if ghost, exist := p.ghosts[e.Key]; exist {
+		p.removeGhost(ghost)
+		p.main.PushFront(e)
+	} else {
+		p.small.PushFront(e)
+	}
+}
+
+func (p *policyS3FIFO) Remove(e *listEntry) {
+	if e.list == p.small || e.list == p.main {
+		e.list.Remove(e)
+	}
+}
+
+func (p *policyS3FIFO) Evict() (e *listEntry) {
+
+	if p.small.Weight() > p.smallCap || p.main.Len() == 0 {
+		if e = p.evictSmall(); e != nil {
+			return
+		}
+	}
+
+	e = p.evictMain()
+	return
+}
+
+func (p *policyS3FIFO) evictSmall() (e *listEntry) {
+
+	for e = p.small.Back(); e != nil; e = p.small.Back() {
+		p.small.Remove(e)
+
+		if atomic.LoadUint32(&e.freq) > 0 {
+			atomic.StoreUint32(&e.freq, 0)
+			p.main.PushFront(e)
+			continue
+		}
+
+		ghost := &listEntry{Key: e.Key, Weight: e.Weight}
+		p.ghost.PushFront(ghost)
+		p.ghosts[ghost.Key] = ghost
+		for p.ghost.Weight() > p.ghostCap {
+			p.removeGhost(p.ghost.Back())
+		}
+
+		return
+	}
+
+	return
+}
+
+func (p *policyS3FIFO) evictMain() (e *listEntry) {
+
+	for e = p.main.Back(); e != nil; e = p.main.Back() {
+		p.main.Remove(e)
+
+		if freq := atomic.LoadUint32(&e.freq); freq > 0 {
+			atomic.StoreUint32(&e.freq, freq-1)
+			p.main.PushFront(e)
+			continue
+		}
+
+		return
+	}
+
+	return
+}
+
+func (p *policyS3FIFO) removeGhost(ghost *listEntry) {
+	p.ghost.Remove(ghost)
+	delete(p.ghosts, ghost.Key)
+}
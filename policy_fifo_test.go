@@ -0,0 +1,86 @@
+package scache
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSIEVEEvict(t *testing.T) {
+
+	p := newPolicySIEVE()
+
+	entries := make([]*listEntry, 4)
+	for i := range entries {
+		entries[i] = &listEntry{Key: i, Weight: 1}
+		p.Insert(entries[i])
+	}
+
+	p.Access(entries[0])
+	p.Access(entries[2])
+
+	require.Same(t, entries[1], p.Evict())
+	require.Equal(t, uint32(0), entries[0].freq)
+	require.Same(t, entries[2], p.hand)
+
+	// the newest entry isn't evicted right after the insert
+	fresh := &listEntry{Key: 4, Weight: 1}
+	p.Insert(fresh)
+	require.Same(t, entries[3], p.Evict())
+	require.Same(t, entries[0], p.Evict())
+	require.Same(t, entries[2], p.Evict())
+	require.Same(t, fresh, p.Evict())
+	require.Nil(t, p.Evict())
+}
+
+func TestS3FIFOEvict(t *testing.T) {
+
+	p := newPolicyS3FIFO(10)
+	require.Equal(t, int64(1), p.smallCap)
+
+	a, b, c := &listEntry{Key: "a", Weight: 1}, &listEntry{Key: "b", Weight: 1}, &listEntry{Key: "c", Weight: 1}
+	p.Insert(a)
+	p.Insert(b)
+	p.Access(a)
+
+	// a was hit in the small queue and goes to the main queue
+	require.Same(t, b, p.Evict())
+	require.Equal(t, p.main, a.list)
+	require.Contains(t, p.ghosts, "b")
+
+	// the key from the ghost queue goes to the main queue
+	p.Insert(c)
+	p.Insert(&listEntry{Key: "b", Weight: 1})
+	require.NotContains(t, p.ghosts, "b")
+	require.Equal(t, 2, p.main.Len())
+
+	// the small queue doesn't exceed its share, so the main queue is evicted
+	require.Same(t, a, p.Evict())
+	require.Equal(t, "b", p.Evict().Key)
+	require.Same(t, c, p.Evict())
+	require.Nil(t, p.Evict())
+}
+
+func TestFIFOPoliciesCapacity(t *testing.T) {
+
+	const Capacity = 10
+
+	for _, policy := range []listPolicy{newPolicySIEVE(), newPolicyS3FIFO(Capacity)} {
+		cache := newShardList(newCounter(Capacity), nil, &Config{Shards: 1, MaxSize: Capacity}, Capacity, policy)
+		require.True(t, cache.sharedAccess)
+
+		cache.Set("hot", "hot")
+		for i := 0; i < Capacity*10; i++ {
+			_, err := cache.Get("hot")
+			require.NoError(t, err)
+
+			cache.Set(strconv.Itoa(i), i)
+			require.LessOrEqual(t, cache.Count(), int64(Capacity))
+		}
+
+		val, err := cache.Get("hot")
+		require.NoError(t, err)
+		require.Equal(t, "hot", val)
+	}
+}
@@ -0,0 +1,32 @@
+package scache
+
+// policyExactLRU keeps entries in the strict LRU order: the hit moves
+// the entry to the front of the list, the entry from the back is evicted.
+type policyExactLRU struct {
+	queue *list
+}
+
+func newPolicyExactLRU() *policyExactLRU {
+	return &policyExactLRU{
+		queue: newList(),
+	}
+}
+
+func (p *policyExactLRU) Access(e *listEntry) {
+	p.queue.MoveToFront(e)
+}
+
+func (p *policyExactLRU) Insert(e *listEntry) {
+	p.queue.PushFront(e)
+}
+
+func (p *policyExactLRU) Remove(e *listEntry) {
+	p.queue.Remove(e)
+}
+
+func (p *policyExactLRU) Evict() (e *listEntry) {
+	if e = p.queue.Back(); e != nil {
+		p.queue.Remove(e)
+	}
+	return
+}
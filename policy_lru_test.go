@@ -0,0 +1,52 @@
+package scache
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExactLRUOrder(t *testing.T) {
+
+	const Capacity = 3
+
+	cache := newShardList(newCounter(Capacity), nil, &Config{Shards: 1, MaxSize: Capacity}, Capacity, newPolicyExactLRU())
+
+	for _, k := range []string{"a", "b", "c"} {
+		cache.Set(k, k)
+	}
+
+	_, err := cache.Get("a")
+	require.NoError(t, err)
+	cache.Set("b", "B")
+
+	for _, testInfo := range []struct {
+		Key     string
+		Evicted string
+	}{
+		{Key: "d", Evicted: "c"},
+		{Key: "e", Evicted: "a"},
+		{Key: "f", Evicted: "b"},
+		{Key: "g", Evicted: "d"},
+	} {
+		cache.Set(testInfo.Key, testInfo.Key)
+		require.Equal(t, int64(Capacity), cache.Count())
+
+		v, err := cache.Get(testInfo.Evicted)
+		require.Equal(t, ErrNotFound, err, testInfo.Key)
+		require.Nil(t, v)
+	}
+
+	require.True(t, cache.Del("f"))
+	cache.Set("h", "h")
+	cache.Set("i", "i")
+	require.Equal(t, []interface{}{"i", "h", "g"}, listKeys(cache.policy.(*policyExactLRU).queue))
+}
+
+// listKeys returns keys from the front of the list to the back.
+func listKeys(l *list) (keys []interface{}) {
+	for e := l.Back(); e != nil; e = l.Prev(e) {
+		keys = append([]interface{}{e.Key}, keys...)
+	}
+	return
+}
@@ -0,0 +1,138 @@
+package scache
+
+const (
+	tinyLFUWindowPercent    = 1
+	tinyLFUProtectedPercent = 80
+	// the capacity is the weight of entries, so the sketch is sized for this
+	// count of entries and it grows with the count of entries of the policy
+	tinyLFUInitialSketchSize = 1 << 12
+)
+
+// policyTinyLFU is the W-TinyLFU policy. New entries get into the small
+// window LRU, entries evicted from the window compete with the victims of
+// the main segmented LRU (probation + protected) and are admitted only if
+// they were more popular than the victim according to the frequency sketch.
+type policyTinyLFU struct {
+	sketch       *sketch
+	window       *list
+	probation    *list
+	protected    *list
+	capacity     int64
+	windowCap    int64
+	mainCap      int64
+	protectedCap int64
+}
+
+func newPolicyTinyLFU(capacity int64) *policyTinyLFU {
+
+	windowCap := capacity * tinyLFUWindowPercent / 100
+	if windowCap < 1 {
+		windowCap = 1
+	}
+
+	mainCap := capacity - windowCap
+	if mainCap < 0 {
+		mainCap = 0
+	}
+
+	sketchSize := capacity
+	if sketchSize > tinyLFUInitialSketchSize {
+		sketchSize = tinyLFUInitialSketchSize
+	}
+
+	return &policyTinyLFU{
+		sketch:       newSketch(sketchSize),
+		window:       newList(),
+		probation:    newList(),
+		protected:    newList(),
+		capacity:     capacity,
+		windowCap:    windowCap,
+		mainCap:      mainCap,
+		protectedCap: mainCap * tinyLFUProtectedPercent / 100,
+	}
+}
+
+func (p *policyTinyLFU) Access(e *listEntry) {
+
+	p.sketch.Increment(e.hash)
+
+	switch e.list {
+	case p.window:
+		p.window.MoveToFront(e)
+
+	case p.probation:
+		p.probation.Remove(e)
+		p.protected.PushFront(e)
+
+		for p.protected.Weight() > p.protectedCap {
+			demoted := p.protected.Back()
+			p.protected.Remove(demoted)
+			p.probation.PushFront(demoted)
+		}
+
+	case p.protected:
+		p.protected.MoveToFront(e)
+	}
+}
+
+func (p *policyTinyLFU) Insert(e *listEntry) {
+
+	// every entry weighs 1 at least, so the count of entries doesn't exceed the capacity
+	if count := int64(p.window.Len()+p.probation.Len()+p.protected.Len()) + 1; count > p.sketch.size {
+		size := 2 * count
+		if size > p.capacity {
+			size = p.capacity
+		}
+		p.sketch.EnsureCapacity(size)
+	}
+
+	e.hash, _ = hashKey(e.Key) // keys of unknown types share the same counters
+	p.sketch.Increment(e.hash)
+	p.window.PushFront(e)
+
+	// the window is drained into the main segment while it has free space
+	for p.window.Weight() > p.windowCap && p.probation.Weight()+p.protected.Weight() < p.mainCap {
+		candidate := p.window.Back()
+		p.window.Remove(candidate)
+		p.probation.PushFront(candidate)
+	}
+}
+
+func (p *policyTinyLFU) Remove(e *listEntry) {
+	if e.list != nil {
+		e.list.Remove(e)
+	}
+}
+
+func (p *policyTinyLFU) Evict() (e *listEntry) {
+
+	victim := p.probation.Back()
+	if victim == nil {
+		victim = p.protected.Back()
+	}
+
+	candidate := p.window.Back()
+	if p.window.Weight() <= p.windowCap && victim != nil {
+		candidate = nil
+	}
+
+	switch {
+	case candidate == nil:
+		e = victim
+	case victim == nil:
+		e = candidate
+	case p.sketch.Estimate(candidate.hash) > p.sketch.Estimate(victim.hash):
+		// the candidate is admitted into the main segment
+		p.window.Remove(candidate)
+		p.probation.PushFront(candidate)
+		e = victim
+	default:
+		e = candidate
+	}
+
+	if e != nil {
+		p.Remove(e)
+	}
+
+	return
+}
@@ -0,0 +1,119 @@
+package scache
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSketch(t *testing.T) {
+
+	s := newSketch(16)
+
+	s.Increment(1)
+	require.Equal(t, uint32(1), s.Estimate(1)) // doorkeeper only
+	require.Equal(t, uint32(0), s.Estimate(2))
+
+	for i := 0; i < 5; i++ {
+		s.Increment(1)
+	}
+	require.Equal(t, uint32(6), s.Estimate(1))
+
+	s.reset()
+	require.Equal(t, uint32(2), s.Estimate(1))
+
+	for i := 0; i < 200; i++ {
+		s.Increment(1)
+	}
+	require.LessOrEqual(t, s.Estimate(1), uint32(sketchCounterMask+1))
+	require.Less(t, s.additions, s.sampleSize)
+}
+
+func TestTinyLFUSetAndGet(t *testing.T) {
+
+	cache := newShardList(newCounter(1000), nil, &Config{}, 1000, newPolicyTinyLFU(1000))
+
+	const Key = "test"
+
+	cache.Set(Key, "TEST DATA1")
+	cache.Set(Key, "TEST DATA2")
+
+	val, err := cache.Get(Key)
+	require.NoError(t, err)
+	require.Equal(t, "TEST DATA2", val)
+	require.Equal(t, int64(1), cache.counter.Count())
+
+	require.True(t, cache.Del(Key))
+	require.False(t, cache.Del(Key))
+	require.Equal(t, int64(0), cache.counter.Count())
+}
+
+func TestTinyLFUScanResistance(t *testing.T) {
+
+	const Capacity = 100
+
+	conf := &Config{Shards: 1, MaxSize: Capacity}
+	cache := newShardList(newCounter(Capacity), nil, conf, Capacity, newPolicyTinyLFU(Capacity))
+
+	hot := make([]string, Capacity/2)
+	for i := range hot {
+		hot[i] = "hot" + strconv.Itoa(i)
+		cache.Set(hot[i], i)
+	}
+
+	for n := 0; n < 3; n++ {
+		for _, k := range hot {
+			_, err := cache.Get(k)
+			require.NoError(t, err)
+		}
+	}
+
+	// the long tail of one-hit keys
+	for i := 0; i < Capacity*5; i++ {
+		cache.Set("cold"+strconv.Itoa(i), i)
+		require.LessOrEqual(t, cache.Count(), int64(Capacity))
+	}
+
+	for i, k := range hot {
+		val, err := cache.Get(k)
+		require.NoError(t, err, k)
+		require.Equal(t, i, val)
+	}
+}
+
+func TestTinyLFUEvictWindow(t *testing.T) {
+
+	p := newPolicyTinyLFU(1)
+	require.Equal(t, int64(1), p.windowCap)
+	require.Equal(t, int64(0), p.mainCap)
+
+	e1 := &listEntry{Key: "1", Weight: 1}
+	p.Insert(e1)
+	e2 := &listEntry{Key: "2", Weight: 1}
+	p.Insert(e2)
+
+	require.Equal(t, e1, p.Evict())
+	require.Equal(t, e2, p.Evict())
+	require.Nil(t, p.Evict())
+}
+
+func TestTinyLFUSketchSize(t *testing.T) {
+
+	// the capacity is the weight, the sketch is sized for the count of entries
+	p := newPolicyTinyLFU(256 << 20)
+	require.Equal(t, int64(tinyLFUInitialSketchSize), p.sketch.size)
+
+	for i := 0; i < 2*tinyLFUInitialSketchSize; i++ {
+		p.Insert(&listEntry{Key: i, Weight: 1})
+	}
+	require.GreaterOrEqual(t, p.sketch.size, int64(2*tinyLFUInitialSketchSize))
+	require.Less(t, p.sketch.size, int64(8*tinyLFUInitialSketchSize))
+
+	// the count of entries doesn't exceed the capacity
+	p = newPolicyTinyLFU(10)
+	for i := 0; i < 100; i++ {
+		p.Insert(&listEntry{Key: i, Weight: 1})
+	}
+	require.Equal(t, int64(10), p.sketch.size)
+}
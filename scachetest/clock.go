@@ -0,0 +1,41 @@
+// Package scachetest provides the helpers for tests of the code which uses the cache.
+package scachetest
+
+import (
+	"sync"
+	"time"
+)
+
+// FakeClock is the clock which time is changed only manually,
+// so the expiration of items is deterministic in tests.
+type FakeClock struct {
+	mu  sync.RWMutex
+	now time.Time
+}
+
+// NewFakeClock returns the clock which is stopped at the time.
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+// Now returns the current time of the clock.
+func (c *FakeClock) Now() (val time.Time) {
+	c.mu.RLock()
+	val = c.now
+	c.mu.RUnlock()
+	return
+}
+
+// Advance moves the time of the clock forward by the duration.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	c.mu.Unlock()
+}
+
+// Set changes the time of the clock.
+func (c *FakeClock) Set(now time.Time) {
+	c.mu.Lock()
+	c.now = now
+	c.mu.Unlock()
+}
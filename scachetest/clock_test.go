@@ -0,0 +1,21 @@
+package scachetest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFakeClock(t *testing.T) {
+
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+	require.Equal(t, start, clock.Now())
+
+	clock.Advance(time.Hour)
+	require.Equal(t, start.Add(time.Hour), clock.Now())
+
+	clock.Set(start)
+	require.Equal(t, start, clock.Now())
+}
@@ -0,0 +1,350 @@
+package scache
+
+import (
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// frequency is kept in the high byte of the cost, the rest of the cost is
+	// the tick of the last access, so items with equal frequency are evicted
+	// in the LRU order.
+	lfuFreqShift = 24
+	lfuMaxFreq   = math.MaxUint32 >> lfuFreqShift
+	lfuTickMask  = 1<<lfuFreqShift - 1
+	// frequencies are halved after 'lfuAgingFactor * count of items of the shard' hits
+	lfuAgingFactor = 10
+)
+
+type itemLFU struct {
+	Value   interface{}
+	Expire  int64 // it's changed atomically if the expiration is sliding
+	TTL     time.Duration
+	Delta   time.Duration
+	Sliding bool
+	Weight  int64
+	Freq    uint32
+	Cost    *uint32
+}
+
+type shardLFU struct {
+	ttl         time.Duration
+	staleTTL    time.Duration
+	sliding     bool
+	clock       Clock
+	counter     *counter
+	timer       *timer
+	payload     map[interface{}]*itemLFU
+	mu          sync.RWMutex
+	chClean     chan struct{}
+	samples     int
+	weigher     WeighFunc
+	hits        uint32
+	agingPeriod uint32
+	notifier    *notifier
+}
+
+func newShardLFU(chClean chan struct{}, counter *counter, tm *timer, notifier *notifier, conf *Config) *shardLFU {
+
+	return &shardLFU{
+		ttl:         conf.TTL,
+		staleTTL:    conf.StaleTTL,
+		sliding:     conf.SlidingExpiration,
+		clock:       conf.clock(),
+		payload:     make(map[interface{}]*itemLFU),
+		counter:     counter,
+		timer:       tm,
+		chClean:     chClean,
+		samples:     conf.EvictionSamples,
+		weigher:     conf.Weigher,
+		agingPeriod: agingPeriodLFU(0),
+		notifier:    notifier,
+	}
+}
+
+func (s *shardLFU) Count() (val int64) {
+	s.mu.RLock()
+	val = int64(len(s.payload))
+	s.mu.RUnlock()
+	return
+}
+
+func (s *shardLFU) Set(key interface{}, value interface{}) {
+	s.setExp(true, key, value, itemOptions{})
+}
+
+func (s *shardLFU) SetExp(key interface{}, value interface{}, ttl time.Duration) {
+	s.setExp(true, key, value, itemOptions{TTL: ttl})
+}
+
+func (s *shardLFU) SetWithCost(key interface{}, value interface{}, cost int64) {
+	s.setExp(true, key, value, itemOptions{Cost: cost})
+}
+
+// Add sets the value if the key doesn't exist or it's expired.
+func (s *shardLFU) Add(key interface{}, value interface{}, opts itemOptions) (ok bool) {
+	ok = s.setExp(false, key, value, opts)
+	return
+}
+
+func (s *shardLFU) Replace(key interface{}, value interface{}, opts itemOptions) (ok bool) {
+	ok = s.setExp(true, key, value, opts)
+	return
+}
+
+func (s *shardLFU) Get(key interface{}) (value interface{}, err error) {
+	value, _, err = s.GetWithMeta(key)
+	return
+}
+
+func (s *shardLFU) GetWithMeta(key interface{}) (value interface{}, meta itemMeta, err error) {
+
+	s.mu.RLock()
+	elem, exist := s.payload[key]
+	s.mu.RUnlock()
+
+	if exist {
+		if now := timeNowLRU(s.clock, 0); isExpired(&elem.Expire, now) {
+			s.DelExpired(key)
+		} else {
+			if elem.Sliding {
+				slideExpire(&elem.Expire, elem.TTL, s.staleTTL, now)
+			}
+			s.hit(elem)
+			value = elem.Value
+			meta = itemMeta{Expire: atomic.LoadInt64(&elem.Expire), TTL: elem.TTL, Delta: elem.Delta}
+			return
+		}
+	}
+
+	err = ErrNotFound
+
+	return
+}
+
+func (s *shardLFU) Del(key interface{}) (ok bool) {
+	return s.Remove(key, RemovalDeleted)
+}
+
+// Remove deletes the key, the reason is passed to the eviction callback.
+func (s *shardLFU) Remove(key interface{}, reason RemovalReason) (ok bool) {
+
+	var removed []removal
+
+	s.mu.Lock()
+	elem, ok := s.del(key)
+	if ok {
+		removed = s.notifier.Removed(removed, key, elem.Value, reason)
+	}
+	s.mu.Unlock()
+
+	s.notifier.PushAll(removed)
+
+	return
+}
+
+// DelExpired deletes the key if it's expired, the expiration time
+// of the key which isn't expired is returned.
+func (s *shardLFU) DelExpired(key interface{}) (expire int64, ok bool) {
+
+	var removed []removal
+
+	s.mu.Lock()
+	if elem, exist := s.payload[key]; exist {
+		if isExpired(&elem.Expire, timeNowLRU(s.clock, 0)) {
+			_, ok = s.del(key)
+			removed = s.notifier.Removed(removed, key, elem.Value, RemovalExpired)
+		} else {
+			expire = atomic.LoadInt64(&elem.Expire)
+		}
+	}
+	s.mu.Unlock()
+
+	s.notifier.PushAll(removed)
+
+	return
+}
+
+// Clear deletes all keys.
+func (s *shardLFU) Clear() {
+
+	var removed []removal
+
+	s.mu.Lock()
+	for k, v := range s.payload {
+		s.counter.Dec(v.Weight)
+		removed = s.notifier.Removed(removed, k, v.Value, RemovalCleared)
+	}
+	s.payload = make(map[interface{}]*itemLFU)
+	s.mu.Unlock()
+
+	s.notifier.PushAll(removed)
+}
+
+func (s *shardLFU) del(key interface{}) (elem *itemLFU, ok bool) {
+
+	elem, ok = s.payload[key]
+	if ok {
+		delete(s.payload, key)
+		s.counter.Dec(elem.Weight)
+	}
+	return
+}
+
+// setExp sets the value, the existing value is replaced only if the flag replace
+// is set or the existing value is expired.
+func (s *shardLFU) setExp(replace bool, key interface{}, value interface{}, opts itemOptions) (ok bool) {
+
+	expire, ttl := expireTime(timeNowLRU(s.clock, 0), opts.TTL, s.ttl, s.staleTTL)
+
+	newItem := &itemLFU{
+		Value:   value,
+		Expire:  expire,
+		TTL:     ttl,
+		Delta:   opts.Delta,
+		Sliding: opts.Sliding || s.sliding,
+		Weight:  weigh(s.weigher, key, value, opts.Cost),
+		Freq:    1,
+	}
+
+	s.mu.Lock()
+
+	var (
+		overflow bool
+		reason   = RemovalReplaced
+		removed  []removal
+	)
+	old, exist := s.payload[key]
+	if exist && isExpired(&old.Expire, timeNowLRU(s.clock, 0)) {
+		reason = RemovalExpired
+	}
+	if ok = (replace || !exist || reason == RemovalExpired) && opts.storable(); ok {
+		if exist {
+			// the replaced value inherits the frequency of the key
+			newItem.Freq = incFreqLFU(&old.Freq)
+		}
+
+		itemCost := costLFU(newItem.Freq, s.timer.Tick())
+		newItem.Cost = &itemCost
+		s.payload[key] = newItem
+
+		if exist {
+			overflow = s.counter.Update(newItem.Weight - old.Weight)
+			removed = s.notifier.Removed(removed, key, old.Value, reason)
+		} else {
+			overflow = s.counter.Inc(newItem.Weight)
+		}
+		s.notifier.Stored(key, value, reason == RemovalReplaced && exist)
+	}
+
+	s.mu.Unlock()
+
+	s.notifier.PushAll(removed)
+
+	// the cleaner removes the items until the overflow ends,
+	// so the notification isn't needed if the cleaner has one
+	if overflow {
+		select {
+		case s.chClean <- struct{}{}:
+		default:
+		}
+	}
+
+	return
+}
+
+func (s *shardLFU) hit(elem *itemLFU) {
+
+	freq := incFreqLFU(&elem.Freq)
+	atomic.StoreUint32(elem.Cost, costLFU(freq, s.timer.Tick()))
+
+	if atomic.AddUint32(&s.hits, 1) >= atomic.LoadUint32(&s.agingPeriod) {
+		s.age()
+	}
+}
+
+// agingPeriodLFU returns the count of hits after which the frequencies are halved.
+// It depends on the count of items, the capacity of the shard is the weight of items.
+func agingPeriodLFU(count int) uint32 {
+
+	if count < 1 {
+		count = 1
+	}
+
+	if v := lfuAgingFactor * int64(count); v < math.MaxUint32 {
+		return uint32(v)
+	}
+
+	return math.MaxUint32
+}
+
+// age halves frequencies of all items, so the items which were popular
+// a long time ago can be evicted.
+func (s *shardLFU) age() {
+
+	s.mu.Lock()
+	if hits := atomic.LoadUint32(&s.hits); hits >= atomic.LoadUint32(&s.agingPeriod) {
+		// the period is updated when it ends, so the count of items
+		// is checked under the lock only
+		period := agingPeriodLFU(len(s.payload))
+		atomic.StoreUint32(&s.agingPeriod, period)
+
+		if hits >= period {
+			atomic.StoreUint32(&s.hits, 0)
+
+			for _, v := range s.payload {
+				freq := atomic.LoadUint32(&v.Freq) >> 1
+				atomic.StoreUint32(&v.Freq, freq)
+				atomic.StoreUint32(v.Cost, costLFU(freq, atomic.LoadUint32(v.Cost)))
+			}
+		}
+	}
+	s.mu.Unlock()
+}
+
+func (s *shardLFU) GetForRemove(expiredKeys *[]interface{}, oldest iListWithOldEntries) {
+
+	var (
+		now            = timeNowLRU(s.clock, 0)
+		expiredKeysLen = len(*expiredKeys)
+		expiredKeysCap = cap(*expiredKeys)
+		sampled        int
+	)
+
+	s.mu.RLock()
+	// the order of the map iteration is random, so the first items are the random sample
+	for k, v := range s.payload {
+		if s.samples > 0 && sampled == s.samples {
+			break
+		}
+		sampled++
+
+		if isExpired(&v.Expire, now) {
+			if expiredKeysLen < expiredKeysCap {
+				*expiredKeys = append(*expiredKeys, k)
+				expiredKeysLen++
+			}
+
+		} else {
+			// the cost doesn't depend on the timer epoch
+			oldest.Add(k, v.Cost, math.MaxUint32)
+		}
+	}
+	s.mu.RUnlock()
+}
+
+func incFreqLFU(freq *uint32) (val uint32) {
+
+	val = atomic.LoadUint32(freq)
+	if val < lfuMaxFreq && atomic.CompareAndSwapUint32(freq, val, val+1) {
+		val++
+	}
+
+	return
+}
+
+func costLFU(freq uint32, tick uint32) uint32 {
+	return freq<<lfuFreqShift | tick&lfuTickMask
+}
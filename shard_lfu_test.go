@@ -0,0 +1,112 @@
+package scache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/khevse/scache/scachetest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLfuSetAndGet(t *testing.T) {
+
+	cache := newShardLFU(nil, newCounter(1000), newTimer(), nil, &Config{
+		TTL: 1 * time.Second,
+	})
+
+	const Key = "test"
+
+	cache.Set(Key, "TEST DATA1")
+	cache.Set(Key, "TEST DATA2")
+
+	val, err := cache.Get(Key)
+	require.NoError(t, err)
+	require.Equal(t, "TEST DATA2", val)
+	require.Equal(t, int64(1), cache.counter.Count())
+	require.Equal(t, uint32(3), cache.payload[Key].Freq)
+}
+
+func TestLfuGetForRemove(t *testing.T) {
+
+	cache := newShardLFU(nil, newCounter(1000), newTimer(), nil, &Config{})
+
+	for _, k := range []string{"a", "b", "c", "d"} {
+		cache.Set(k, k)
+	}
+
+	for _, k := range []string{"a", "c", "a", "d", "a"} {
+		_, err := cache.Get(k)
+		require.NoError(t, err)
+	}
+
+	expiredKeys := make([]interface{}, 0, 10)
+	oldest := newListWithOldEntriesLRU(10)
+	cache.GetForRemove(&expiredKeys, oldest)
+	require.Empty(t, expiredKeys)
+
+	keys := make([]interface{}, 0)
+	for {
+		k, ok := oldest.Next()
+		if !ok {
+			break
+		}
+		keys = append(keys, k)
+	}
+
+	// equal frequencies are ordered by the last access
+	require.Equal(t, []interface{}{"b", "c", "d", "a"}, keys)
+}
+
+func TestLfuAging(t *testing.T) {
+
+	cache := newShardLFU(nil, newCounter(1000), newTimer(), nil, &Config{
+		Shards:  1,
+		MaxSize: 1,
+	})
+	require.Equal(t, uint32(lfuAgingFactor), cache.agingPeriod)
+
+	cache.Set("key", "DATA")
+	for i := 0; i < lfuAgingFactor; i++ {
+		_, err := cache.Get("key")
+		require.NoError(t, err)
+	}
+
+	require.Equal(t, uint32(lfuAgingFactor+1)>>1, cache.payload["key"].Freq)
+	require.Equal(t, uint32(0), cache.hits)
+
+	// the period depends on the count of items, not on the weight
+	cache.Set("key2", "DATA")
+	for i := 0; i < lfuAgingFactor; i++ {
+		_, err := cache.Get("key")
+		require.NoError(t, err)
+	}
+	require.Equal(t, uint32(2*lfuAgingFactor), cache.agingPeriod)
+	require.Equal(t, uint32(lfuAgingFactor), cache.hits)
+}
+
+func TestLfuTTL(t *testing.T) {
+
+	clock := scachetest.NewFakeClock(time.Now())
+	cache := newShardLFU(nil, newCounter(1000), newTimer(), nil, &Config{
+		TTL:   10 * time.Millisecond,
+		Clock: clock,
+	})
+
+	cache.Set("key", "DATA")
+	{
+		v, err := cache.Get("key")
+		require.NoError(t, err)
+		require.Equal(t, "DATA", v)
+	}
+
+	clock.Advance(cache.ttl)
+
+	expiredKeys := make([]interface{}, 0, 10)
+	cache.GetForRemove(&expiredKeys, newListWithOldEntriesLRU(1))
+	require.Equal(t, []interface{}{"key"}, expiredKeys)
+
+	v, err := cache.Get("key")
+	require.Equal(t, ErrNotFound, err)
+	require.Nil(t, v)
+	require.Equal(t, int64(0), cache.Count())
+}
@@ -0,0 +1,322 @@
+package scache
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// listPolicy keeps the order of the shard entries and decides which entry
+// has to be evicted. Methods are called under the lock of the shard.
+type listPolicy interface {
+	// Access is called when an existing entry is read or replaced
+	Access(e *listEntry)
+	// Insert links the new entry
+	Insert(e *listEntry)
+	// Remove unlinks the entry which is deleted from the shard
+	Remove(e *listEntry)
+	// Evict unlinks and returns the entry which has to be evicted
+	Evict() *listEntry
+}
+
+// sharedAccessPolicy is implemented by the policies which only mark
+// the entry on access, so Access is called under the read lock of the shard.
+type sharedAccessPolicy interface {
+	SharedAccess()
+}
+
+// shardList is a shard which evicts entries itself when the weight of entries
+// exceeds the capacity of the shard (its part of MaxSize), so it doesn't use
+// the cleaner for the eviction.
+type shardList struct {
+	ttl      time.Duration
+	staleTTL time.Duration
+	sliding  bool
+	clock    Clock
+	capacity int64
+	weight   int64
+	weigher  WeighFunc
+	counter  *counter
+	policy   listPolicy
+	payload  map[interface{}]*listEntry
+	mu       sync.RWMutex
+	// the removed entries are passed to the eviction callback after the unlock
+	notifier *notifier
+	// policy.Access can be called under the read lock
+	sharedAccess bool
+}
+
+func newShardList(counter *counter, notifier *notifier, conf *Config, capacity int64, policy listPolicy) *shardList {
+
+	_, sharedAccess := policy.(sharedAccessPolicy)
+
+	return &shardList{
+		ttl:          conf.TTL,
+		staleTTL:     conf.StaleTTL,
+		sliding:      conf.SlidingExpiration,
+		clock:        conf.clock(),
+		capacity:     capacity,
+		weigher:      conf.Weigher,
+		counter:      counter,
+		policy:       policy,
+		payload:      make(map[interface{}]*listEntry),
+		sharedAccess: sharedAccess,
+		notifier:     notifier,
+	}
+}
+
+func (s *shardList) Count() (val int64) {
+	s.mu.RLock()
+	val = int64(len(s.payload))
+	s.mu.RUnlock()
+	return
+}
+
+func (s *shardList) Set(key interface{}, value interface{}) {
+	s.mu.Lock()
+	removed := s.setExp(key, value, itemOptions{})
+	s.mu.Unlock()
+	s.notifier.PushAll(removed)
+}
+
+func (s *shardList) SetExp(key interface{}, value interface{}, ttl time.Duration) {
+	s.mu.Lock()
+	removed := s.setExp(key, value, itemOptions{TTL: ttl})
+	s.mu.Unlock()
+	s.notifier.PushAll(removed)
+}
+
+func (s *shardList) SetWithCost(key interface{}, value interface{}, cost int64) {
+	s.mu.Lock()
+	removed := s.setExp(key, value, itemOptions{Cost: cost})
+	s.mu.Unlock()
+	s.notifier.PushAll(removed)
+}
+
+// Add sets the value if the key doesn't exist or it's expired.
+func (s *shardList) Add(key interface{}, value interface{}, opts itemOptions) (ok bool) {
+	var removed []removal
+	s.mu.Lock()
+	elem, exist := s.payload[key]
+	if ok = (!exist || isExpired(&elem.Expire, timeNowLRU(s.clock, 0))) && opts.storable(); ok {
+		removed = s.setExp(key, value, opts)
+	}
+	s.mu.Unlock()
+	s.notifier.PushAll(removed)
+	return
+}
+
+func (s *shardList) Replace(key interface{}, value interface{}, opts itemOptions) (ok bool) {
+	var removed []removal
+	s.mu.Lock()
+	if ok = opts.storable(); ok {
+		removed = s.setExp(key, value, opts)
+	}
+	s.mu.Unlock()
+	s.notifier.PushAll(removed)
+	return
+}
+
+func (s *shardList) Get(key interface{}) (value interface{}, err error) {
+	value, _, err = s.GetWithMeta(key)
+	return
+}
+
+func (s *shardList) GetWithMeta(key interface{}) (value interface{}, meta itemMeta, err error) {
+
+	if s.sharedAccess {
+		s.mu.RLock()
+		elem, exist := s.payload[key]
+		if now := timeNowLRU(s.clock, 0); exist && !isExpired(&elem.Expire, now) {
+			if elem.Sliding {
+				slideExpire(&elem.Expire, elem.TTL, s.staleTTL, now)
+			}
+			s.policy.Access(elem)
+			value = elem.Value
+			meta = itemMeta{Expire: atomic.LoadInt64(&elem.Expire), TTL: elem.TTL, Delta: elem.Delta}
+			s.mu.RUnlock()
+			return
+		}
+		s.mu.RUnlock()
+	}
+
+	var removed []removal
+
+	s.mu.Lock()
+
+	elem, exist := s.payload[key]
+	if exist {
+		if now := timeNowLRU(s.clock, 0); isExpired(&elem.Expire, now) {
+			s.del(key)
+			removed = s.notifier.Removed(removed, key, elem.Value, RemovalExpired)
+		} else {
+			if elem.Sliding {
+				slideExpire(&elem.Expire, elem.TTL, s.staleTTL, now)
+			}
+			s.policy.Access(elem)
+			value = elem.Value
+			meta = itemMeta{Expire: atomic.LoadInt64(&elem.Expire), TTL: elem.TTL, Delta: elem.Delta}
+			s.mu.Unlock()
+			return
+		}
+	}
+
+	err = ErrNotFound
+
+	s.mu.Unlock()
+
+	s.notifier.PushAll(removed)
+
+	return
+}
+
+func (s *shardList) Del(key interface{}) (ok bool) {
+	return s.Remove(key, RemovalDeleted)
+}
+
+// Remove deletes the key, the reason is passed to the eviction callback.
+func (s *shardList) Remove(key interface{}, reason RemovalReason) (ok bool) {
+
+	var removed []removal
+
+	s.mu.Lock()
+	elem, ok := s.del(key)
+	if ok {
+		removed = s.notifier.Removed(removed, key, elem.Value, reason)
+	}
+	s.mu.Unlock()
+
+	s.notifier.PushAll(removed)
+
+	return
+}
+
+// DelExpired deletes the key if it's expired, the expiration time
+// of the key which isn't expired is returned.
+func (s *shardList) DelExpired(key interface{}) (expire int64, ok bool) {
+
+	var removed []removal
+
+	s.mu.Lock()
+	if elem, exist := s.payload[key]; exist {
+		if isExpired(&elem.Expire, timeNowLRU(s.clock, 0)) {
+			_, ok = s.del(key)
+			removed = s.notifier.Removed(removed, key, elem.Value, RemovalExpired)
+		} else {
+			expire = atomic.LoadInt64(&elem.Expire)
+		}
+	}
+	s.mu.Unlock()
+
+	s.notifier.PushAll(removed)
+
+	return
+}
+
+// Clear deletes all keys.
+func (s *shardList) Clear() {
+
+	var removed []removal
+
+	s.mu.Lock()
+	for k, v := range s.payload {
+		s.del(k)
+		removed = s.notifier.Removed(removed, k, v.Value, RemovalCleared)
+	}
+	s.mu.Unlock()
+
+	s.notifier.PushAll(removed)
+}
+
+func (s *shardList) del(key interface{}) (elem *listEntry, ok bool) {
+
+	elem, ok = s.payload[key]
+	if ok {
+		s.policy.Remove(elem)
+		delete(s.payload, key)
+		s.weight -= elem.Weight
+		s.counter.Dec(elem.Weight)
+	}
+	return
+}
+
+// setExp sets the value, it returns the removed entries
+// which have to be passed to the eviction callback.
+func (s *shardList) setExp(key interface{}, value interface{}, opts itemOptions) (removed []removal) {
+
+	now := timeNowLRU(s.clock, 0)
+	expire, ttl := expireTime(now, opts.TTL, s.ttl, s.staleTTL)
+
+	weight := weigh(s.weigher, key, value, opts.Cost)
+
+	if elem, exist := s.payload[key]; exist {
+		reason := RemovalReplaced
+		if isExpired(&elem.Expire, now) {
+			reason = RemovalExpired
+		}
+		removed = s.notifier.Removed(removed, key, elem.Value, reason)
+
+		elem.Value = value
+		elem.Expire = expire
+		elem.TTL = ttl
+		elem.Delta = opts.Delta
+		elem.Sliding = opts.Sliding || s.sliding
+		s.weight += weight - elem.Weight
+		s.counter.Update(weight - elem.Weight)
+		elem.SetWeight(weight)
+		s.policy.Access(elem)
+		s.notifier.Stored(key, value, reason == RemovalReplaced)
+
+	} else {
+		elem = &listEntry{
+			Key:     key,
+			Value:   value,
+			Expire:  expire,
+			TTL:     ttl,
+			Delta:   opts.Delta,
+			Sliding: opts.Sliding || s.sliding,
+			Weight:  weight,
+		}
+		s.payload[key] = elem
+		s.policy.Insert(elem)
+		s.weight += weight
+		s.counter.Inc(weight)
+		s.notifier.Stored(key, value, false)
+	}
+
+	for s.weight > s.capacity {
+		victim := s.policy.Evict()
+		if victim == nil {
+			break
+		}
+
+		delete(s.payload, victim.Key)
+		s.weight -= victim.Weight
+		s.counter.Dec(victim.Weight)
+		removed = s.notifier.Removed(removed, victim.Key, victim.Value, RemovalEvicted)
+	}
+
+	return
+}
+
+func (s *shardList) GetForRemove(expiredKeys *[]interface{}, _ iListWithOldEntries) {
+
+	var (
+		now            = timeNowLRU(s.clock, 0)
+		expiredKeysLen = len(*expiredKeys)
+		expiredKeysCap = cap(*expiredKeys)
+	)
+
+	s.mu.RLock()
+	for k, v := range s.payload {
+		if expiredKeysLen == expiredKeysCap {
+			break
+		}
+
+		if isExpired(&v.Expire, now) {
+			*expiredKeys = append(*expiredKeys, k)
+			expiredKeysLen++
+		}
+	}
+	s.mu.RUnlock()
+}
@@ -7,31 +7,45 @@ import (
 )
 
 type itemLRU struct {
-	Value  interface{}
-	Expire int64
-	Cost   *uint32
+	Value   interface{}
+	Expire  int64 // it's changed atomically if the expiration is sliding
+	TTL     time.Duration
+	Delta   time.Duration
+	Sliding bool
+	Weight  int64
+	Cost    *uint32
 }
 
 type shardRU struct {
 	ttl          time.Duration
+	staleTTL     time.Duration
+	sliding      bool
+	clock        Clock
 	itemsToPrune uint32
 	counter      *counter
 	timer        *timer
 	payload      map[interface{}]*itemLRU
-	loadFunc     LoadFunc
 	mu           sync.RWMutex
 	chClean      chan struct{}
+	samples      int
+	weigher      WeighFunc
+	notifier     *notifier
 }
 
-func newShardRU(chClean chan struct{}, counter *counter, tm *timer, conf *Config, loadFunc LoadFunc) *shardRU {
+func newShardRU(chClean chan struct{}, counter *counter, tm *timer, notifier *notifier, conf *Config) *shardRU {
 	return &shardRU{
 		ttl:          conf.TTL,
+		staleTTL:     conf.StaleTTL,
+		sliding:      conf.SlidingExpiration,
+		clock:        conf.clock(),
 		itemsToPrune: conf.ItemsToPrune,
 		payload:      make(map[interface{}]*itemLRU),
-		loadFunc:     loadFunc,
 		counter:      counter,
 		timer:        tm,
 		chClean:      chClean,
+		samples:      conf.EvictionSamples,
+		weigher:      conf.Weigher,
+		notifier:     notifier,
 	}
 }
 
@@ -43,14 +57,34 @@ func (s *shardRU) Count() (val int64) {
 }
 
 func (s *shardRU) Set(key interface{}, value interface{}) {
-	s.setExp(true, key, value, 0)
+	s.setExp(true, key, value, itemOptions{})
 }
 
 func (s *shardRU) SetExp(key interface{}, value interface{}, ttl time.Duration) {
-	s.setExp(true, key, value, ttl)
+	s.setExp(true, key, value, itemOptions{TTL: ttl})
+}
+
+func (s *shardRU) SetWithCost(key interface{}, value interface{}, cost int64) {
+	s.setExp(true, key, value, itemOptions{Cost: cost})
+}
+
+// Add sets the value if the key doesn't exist or it's expired.
+func (s *shardRU) Add(key interface{}, value interface{}, opts itemOptions) (ok bool) {
+	ok = s.setExp(false, key, value, opts)
+	return
+}
+
+func (s *shardRU) Replace(key interface{}, value interface{}, opts itemOptions) (ok bool) {
+	ok = s.setExp(true, key, value, opts)
+	return
 }
 
 func (s *shardRU) Get(key interface{}) (value interface{}, err error) {
+	value, _, err = s.GetWithMeta(key)
+	return
+}
+
+func (s *shardRU) GetWithMeta(key interface{}) (value interface{}, meta itemMeta, err error) {
 
 	s.mu.RLock()
 	elem, exist := s.payload[key]
@@ -59,96 +93,165 @@ func (s *shardRU) Get(key interface{}) (value interface{}, err error) {
 	if exist {
 		cost := s.timer.Tick()
 		atomic.StoreUint32(elem.Cost, cost)
-		if elem.Expire != 0 && elem.Expire < timeNowLRU(0) {
-			s.Del(key)
+		if now := timeNowLRU(s.clock, 0); isExpired(&elem.Expire, now) {
+			s.DelExpired(key)
 		} else {
+			if elem.Sliding {
+				slideExpire(&elem.Expire, elem.TTL, s.staleTTL, now)
+			}
 			value = elem.Value
+			meta = itemMeta{Expire: atomic.LoadInt64(&elem.Expire), TTL: elem.TTL, Delta: elem.Delta}
 			return
 		}
 	}
 
-	if s.loadFunc != nil {
-		s.mu.Lock()
-		elem, exist := s.payload[key]
-		if exist {
-			// if has already loaded
-			value = elem.Value
-			s.mu.Unlock()
-			return
-		}
+	err = ErrNotFound
 
-		value, err = s.loadFunc(key)
-		if err == nil {
-			s.setExp(false, key, value, 0)
-		}
-		s.mu.Unlock()
+	return
+}
 
-	} else {
-		err = ErrNotFound
+func (s *shardRU) Del(key interface{}) (ok bool) {
+	return s.Remove(key, RemovalDeleted)
+}
+
+// Remove deletes the key, the reason is passed to the eviction callback.
+func (s *shardRU) Remove(key interface{}, reason RemovalReason) (ok bool) {
+
+	var removed []removal
+
+	s.mu.Lock()
+	elem, ok := s.del(key)
+	if ok {
+		removed = s.notifier.Removed(removed, key, elem.Value, reason)
 	}
+	s.mu.Unlock()
+
+	s.notifier.PushAll(removed)
 
 	return
 }
 
-func (s *shardRU) Del(key interface{}) (ok bool) {
+// DelExpired deletes the key if it's expired, the expiration time
+// of the key which isn't expired is returned.
+func (s *shardRU) DelExpired(key interface{}) (expire int64, ok bool) {
+
+	var removed []removal
+
 	s.mu.Lock()
-	ok = s.del(key)
+	if elem, exist := s.payload[key]; exist {
+		if isExpired(&elem.Expire, timeNowLRU(s.clock, 0)) {
+			_, ok = s.del(key)
+			removed = s.notifier.Removed(removed, key, elem.Value, RemovalExpired)
+		} else {
+			expire = atomic.LoadInt64(&elem.Expire)
+		}
+	}
 	s.mu.Unlock()
+
+	s.notifier.PushAll(removed)
+
 	return
 }
 
-func (s *shardRU) del(key interface{}) (ok bool) {
+// Clear deletes all keys.
+func (s *shardRU) Clear() {
 
-	_, ok = s.payload[key]
+	var removed []removal
+
+	s.mu.Lock()
+	for k, v := range s.payload {
+		s.counter.Dec(v.Weight)
+		removed = s.notifier.Removed(removed, k, v.Value, RemovalCleared)
+	}
+	s.payload = make(map[interface{}]*itemLRU)
+	s.mu.Unlock()
+
+	s.notifier.PushAll(removed)
+}
+
+func (s *shardRU) del(key interface{}) (elem *itemLRU, ok bool) {
+
+	elem, ok = s.payload[key]
 	if ok {
 		delete(s.payload, key)
-		s.counter.Dec()
+		s.counter.Dec(elem.Weight)
 	}
 	return
 }
 
-func (s *shardRU) setExp(lock bool, key interface{}, value interface{}, ttl time.Duration) {
+// setExp sets the value, the existing value is replaced only if the flag replace
+// is set or the existing value is expired.
+func (s *shardRU) setExp(replace bool, key interface{}, value interface{}, opts itemOptions) (ok bool) {
 
-	var expire int64
-	if ttl == 0 && s.ttl > 0 {
-		expire = timeNowLRU(s.ttl)
-	}
+	expire, ttl := expireTime(timeNowLRU(s.clock, 0), opts.TTL, s.ttl, s.staleTTL)
 
-	cost := s.timer.Tick()
+	tick := s.timer.Tick()
 	newItem := &itemLRU{
-		Value:  value,
-		Expire: expire,
-		Cost:   &cost,
+		Value:   value,
+		Expire:  expire,
+		TTL:     ttl,
+		Delta:   opts.Delta,
+		Sliding: opts.Sliding || s.sliding,
+		Weight:  weigh(s.weigher, key, value, opts.Cost),
+		Cost:    &tick,
 	}
 
-	if lock {
-		s.mu.Lock()
+	s.mu.Lock()
+
+	var (
+		overflow bool
+		reason   = RemovalReplaced
+		removed  []removal
+	)
+	old, exist := s.payload[key]
+	if exist && isExpired(&old.Expire, timeNowLRU(s.clock, 0)) {
+		reason = RemovalExpired
+	}
+	if ok = (replace || !exist || reason == RemovalExpired) && opts.storable(); ok {
+		if exist {
+			overflow = s.counter.Update(newItem.Weight - old.Weight)
+			removed = s.notifier.Removed(removed, key, old.Value, reason)
+		} else {
+			overflow = s.counter.Inc(newItem.Weight)
+		}
+		s.payload[key] = newItem
+		s.notifier.Stored(key, value, reason == RemovalReplaced && exist)
 	}
 
-	s.payload[key] = newItem
-	overflow := s.counter.Inc()
+	s.mu.Unlock()
 
-	if lock {
-		s.mu.Unlock()
-	}
+	s.notifier.PushAll(removed)
 
+	// the cleaner removes the items until the overflow ends,
+	// so the notification isn't needed if the cleaner has one
 	if overflow {
-		s.chClean <- struct{}{}
+		select {
+		case s.chClean <- struct{}{}:
+		default:
+		}
 	}
+
+	return
 }
 
 func (s *shardRU) GetForRemove(expiredKeys *[]interface{}, oldest iListWithOldEntries) {
 
 	var (
-		now            = timeNowLRU(0)
+		now            = timeNowLRU(s.clock, 0)
 		expiredKeysLen = len(*expiredKeys)
 		expiredKeysCap = cap(*expiredKeys)
+		sampled        int
 	)
 
 	s.mu.RLock()
+	// the order of the map iteration is random, so the first items are the random sample
 	for k, v := range s.payload {
+		if s.samples > 0 && sampled == s.samples {
+			break
+		}
+		sampled++
 
-		if v.Expire <= now {
+		if isExpired(&v.Expire, now) {
 			if expiredKeysLen < expiredKeysCap {
 				*expiredKeys = append(*expiredKeys, k)
 				expiredKeysLen++
@@ -161,11 +264,48 @@ func (s *shardRU) GetForRemove(expiredKeys *[]interface{}, oldest iListWithOldEn
 	s.mu.RUnlock()
 }
 
-func timeNowLRU(add time.Duration) (v int64) {
+// expireTime returns the expiration time and the lifetime of the item,
+// the default lifetime is used if the lifetime of the item isn't set
+// and the item doesn't expire if its lifetime is NoExpire. The item with
+// other negative lifetime is expired already.
+// The item is kept during the stale period after the end of its lifetime.
+func expireTime(now int64, ttl time.Duration, defaultTTL time.Duration, staleTTL time.Duration) (v int64, lifetime time.Duration) {
+
+	if ttl == 0 {
+		ttl = defaultTTL
+	}
+
+	switch {
+	case ttl > 0:
+		v, lifetime = now+int64(ttl+staleTTL), ttl
+	case ttl < 0 && ttl != NoExpire:
+		v = now
+	}
+
+	return
+}
+
+// slideExpire moves the expiration time of the item with the sliding
+// expiration forward by its lifetime. The stale item isn't moved,
+// it has to be reloaded.
+func slideExpire(expire *int64, ttl time.Duration, staleTTL time.Duration, now int64) {
+	if ttl > 0 && atomic.LoadInt64(expire)-int64(staleTTL) >= now {
+		atomic.StoreInt64(expire, now+int64(ttl+staleTTL))
+	}
+}
+
+// isExpired returns true if the expiration time has passed, the expiration
+// time is loaded atomically because it's changed by the sliding expiration.
+func isExpired(expire *int64, now int64) bool {
+	v := atomic.LoadInt64(expire)
+	return v != 0 && v <= now
+}
+
+func timeNowLRU(clock Clock, add time.Duration) (v int64) {
 	if add == 0 {
-		v = time.Now().UnixNano()
+		v = clock.Now().UnixNano()
 	} else {
-		v = time.Now().Add(add).UnixNano()
+		v = clock.Now().Add(add).UnixNano()
 	}
 	return
 }
@@ -333,6 +473,8 @@ func (e *epoch) Clear() {
 	for _, item := range e.items {
 		item.Clear()
 	}
+	e.lastIdx = 0
+	e.readed = 0
 }
 
 func (e *epoch) Next() (key interface{}, ok bool) {
@@ -4,14 +4,15 @@ import (
 	"testing"
 	"time"
 
+	"github.com/khevse/scache/scachetest"
 	"github.com/stretchr/testify/require"
 )
 
 func TestLruSetAndGet(t *testing.T) {
 
-	cache := newShardRU(nil, newCounter(1000), newTimer(), &Config{
+	cache := newShardRU(nil, newCounter(1000), newTimer(), nil, &Config{
 		TTL: 1 * time.Second,
-	}, nil)
+	})
 
 	const Key = "test"
 
@@ -21,6 +22,7 @@ func TestLruSetAndGet(t *testing.T) {
 	val, err := cache.Get(Key)
 	require.NoError(t, err)
 	require.Equal(t, "TEST DATA2", val)
+	require.Equal(t, int64(1), cache.counter.Count())
 }
 
 func TestLruOldestList(t *testing.T) {
@@ -160,9 +162,11 @@ func TestLruOldestList(t *testing.T) {
 func TestLruTTL(t *testing.T) {
 
 	chClean := make(chan struct{}, 10)
-	cache := newShardRU(chClean, newCounter(2), newTimer(), &Config{
-		TTL: 1 * time.Second,
-	}, nil)
+	clock := scachetest.NewFakeClock(time.Now())
+	cache := newShardRU(chClean, newCounter(2), newTimer(), nil, &Config{
+		TTL:   1 * time.Second,
+		Clock: clock,
+	})
 
 	cache.Set("test1", "test1")
 	cache.Set("test2", "test2")
@@ -179,7 +183,7 @@ func TestLruTTL(t *testing.T) {
 		require.Equal(t, "test3", v)
 	}
 
-	time.Sleep(cache.ttl + time.Millisecond/2)
+	clock.Advance(cache.ttl)
 
 	for _, k := range []string{"test1", "test2"} {
 		v, err := cache.Get(k)
@@ -202,10 +206,10 @@ func TestLruTTL(t *testing.T) {
 
 func TestLruGetUnsetted(t *testing.T) {
 
-	cache := newShardRU(nil, newCounter(1000), newTimer(), &Config{
+	cache := newShardRU(nil, newCounter(1000), newTimer(), nil, &Config{
 		TTL:          1 * time.Second,
 		ItemsToPrune: 1,
-	}, nil)
+	})
 
 	v, err := cache.Get("test")
 	require.Equal(t, ErrNotFound, err)
@@ -214,10 +218,10 @@ func TestLruGetUnsetted(t *testing.T) {
 
 func TestLruDel(t *testing.T) {
 
-	cache := newShardRU(nil, newCounter(2), newTimer(), &Config{
+	cache := newShardRU(nil, newCounter(2), newTimer(), nil, &Config{
 		TTL:          1 * time.Second,
 		ItemsToPrune: 1,
-	}, nil)
+	})
 
 	cache.Set("key", "DATA")
 	cache.Del("key")
@@ -226,3 +230,103 @@ func TestLruDel(t *testing.T) {
 	require.Equal(t, ErrNotFound, err)
 	require.Nil(t, v)
 }
+
+func TestLruSampledGetForRemove(t *testing.T) {
+
+	cache := newShardRU(nil, newCounter(1000), newTimer(), nil, &Config{
+		EvictionSamples: 3,
+	})
+
+	for i := 0; i < 10; i++ {
+		cache.Set(i, i)
+	}
+	cache.timer.Tick() // the last item has the same cost as the timer
+
+	expiredKeys := make([]interface{}, 0, 10)
+	oldest := newListWithOldEntriesLRU(10)
+	cache.GetForRemove(&expiredKeys, oldest)
+	require.Empty(t, expiredKeys)
+
+	count := 0
+	for _, ok := oldest.Next(); ok; _, ok = oldest.Next() {
+		count++
+	}
+	require.Equal(t, 3, count)
+}
+
+func TestExpireTime(t *testing.T) {
+
+	for _, testInfo := range []struct {
+		TTL, DefaultTTL, StaleTTL time.Duration
+		Expire, Lifetime          time.Duration // 0 if the item doesn't expire
+	}{
+		{TTL: 0, DefaultTTL: 0},
+		{TTL: 0, DefaultTTL: time.Hour, Expire: time.Hour, Lifetime: time.Hour},
+		{TTL: time.Minute, DefaultTTL: 0, Expire: time.Minute, Lifetime: time.Minute},
+		{TTL: time.Minute, DefaultTTL: time.Hour, Expire: time.Minute, Lifetime: time.Minute},
+		{TTL: NoExpire, DefaultTTL: 0},
+		{TTL: NoExpire, DefaultTTL: time.Hour},
+		{TTL: time.Minute, DefaultTTL: time.Hour, StaleTTL: time.Hour, Expire: time.Hour + time.Minute, Lifetime: time.Minute},
+		{TTL: NoExpire, DefaultTTL: time.Hour, StaleTTL: time.Hour},
+	} {
+		const now = int64(1000)
+		expire, lifetime := expireTime(now, testInfo.TTL, testInfo.DefaultTTL, testInfo.StaleTTL)
+		require.Equal(t, testInfo.Lifetime, lifetime, testInfo)
+
+		if testInfo.Expire == 0 {
+			require.Equal(t, int64(0), expire, testInfo)
+		} else {
+			require.Equal(t, now+int64(testInfo.Expire), expire, testInfo)
+		}
+	}
+
+	// the item with the negative lifetime which isn't NoExpire is expired already
+	expire, lifetime := expireTime(1000, -time.Second, time.Hour, time.Hour)
+	require.Equal(t, int64(1000), expire)
+	require.Equal(t, time.Duration(0), lifetime)
+	require.True(t, isExpired(&expire, 1000))
+}
+
+func TestLruGetWithMeta(t *testing.T) {
+
+	clock := scachetest.NewFakeClock(time.Now())
+	cache := newShardRU(nil, newCounter(1000), newTimer(), nil, &Config{
+		TTL:   time.Hour,
+		Clock: clock,
+	})
+
+	cache.Set("default", "DATA")
+	cache.Replace("custom", "DATA", itemOptions{TTL: time.Minute, Delta: time.Millisecond})
+
+	_, meta, err := cache.GetWithMeta("default")
+	require.NoError(t, err)
+	require.Equal(t, time.Hour, meta.TTL)
+	require.Equal(t, clock.Now().Add(time.Hour).UnixNano(), meta.Expire)
+
+	_, meta, err = cache.GetWithMeta("custom")
+	require.NoError(t, err)
+	require.Equal(t, time.Minute, meta.TTL)
+	require.Equal(t, time.Millisecond, meta.Delta)
+	require.Equal(t, clock.Now().Add(time.Minute).UnixNano(), meta.Expire)
+}
+
+func TestLruAdd(t *testing.T) {
+
+	clock := scachetest.NewFakeClock(time.Now())
+	cache := newShardRU(nil, newCounter(1000), newTimer(), nil, &Config{Clock: clock})
+
+	require.True(t, cache.Add("key", "DATA1", itemOptions{}))
+	require.False(t, cache.Add("key", "DATA2", itemOptions{}))
+
+	val, err := cache.Get("key")
+	require.NoError(t, err)
+	require.Equal(t, "DATA1", val)
+	require.Equal(t, int64(1), cache.counter.Count())
+
+	// the expired value is replaced
+	require.True(t, cache.Add("expired", "DATA1", itemOptions{TTL: time.Millisecond, Cost: 5}))
+	require.Equal(t, int64(6), cache.counter.Weight())
+	clock.Advance(2 * time.Millisecond)
+	require.True(t, cache.Add("expired", "DATA2", itemOptions{TTL: time.Hour}))
+	require.Equal(t, int64(2), cache.counter.Weight())
+}
@@ -0,0 +1,164 @@
+package scache
+
+const (
+	sketchDepth = 4
+	// counters are halved after 'sketchSampleFactor * size' additions
+	sketchSampleFactor = 10
+	sketchCounterMask  = 0x0f
+	sketchResetMask    = 0x7777777777777777
+)
+
+// sketch is a count-min sketch with 4-bit counters which estimates
+// the popularity of keys. Keys which were seen only once are kept in
+// the doorkeeper (bloom filter) and don't occupy the counters. All counters
+// are halved and the doorkeeper is cleared periodically, so the sketch
+// represents the recent popularity only.
+// The sketch isn't thread safe.
+type sketch struct {
+	table      []uint64 // 16 counters per word
+	mask       uint64
+	doorkeeper []uint64
+	doorMask   uint64
+	additions  int64
+	sampleSize int64
+	size       int64
+}
+
+// newSketch returns the sketch for the count of items.
+func newSketch(size int64) *sketch {
+
+	sampleSize := sketchSampleFactor * size
+
+	// 16 counters per item like in Caffeine
+	counters := nextPowerOfTwo(uint64(size) * 16)
+	if counters < 64 {
+		counters = 64
+	}
+
+	// the doorkeeper has to keep all keys of the sample
+	doorBits := nextPowerOfTwo(uint64(sampleSize) * 4)
+	if doorBits < 64 {
+		doorBits = 64
+	}
+
+	return &sketch{
+		table:      make([]uint64, counters/16),
+		mask:       counters - 1,
+		doorkeeper: make([]uint64, doorBits/64),
+		doorMask:   doorBits - 1,
+		sampleSize: sampleSize,
+		size:       size,
+	}
+}
+
+// EnsureCapacity grows the sketch if the count of items exceeds its size,
+// the frequencies are lost like in Caffeine.
+func (s *sketch) EnsureCapacity(size int64) {
+	if size > s.size {
+		*s = *newSketch(size)
+	}
+}
+
+func (s *sketch) Increment(hash uint64) {
+
+	h1, h2 := spreadHash(hash)
+
+	// the first occurrence of the key is kept by the doorkeeper only
+	if s.admitDoorkeeper(h1, h2) {
+		for i := uint64(0); i < sketchDepth; i++ {
+			idx := (h1 + i*h2) & s.mask
+			word, shift := idx>>4, (idx&15)<<2
+			if (s.table[word]>>shift)&sketchCounterMask < sketchCounterMask {
+				s.table[word] += 1 << shift
+			}
+		}
+	}
+
+	s.additions++
+	if s.additions >= s.sampleSize {
+		s.reset()
+	}
+}
+
+func (s *sketch) Estimate(hash uint64) (val uint32) {
+
+	h1, h2 := spreadHash(hash)
+
+	val = sketchCounterMask
+	for i := uint64(0); i < sketchDepth; i++ {
+		idx := (h1 + i*h2) & s.mask
+		word, shift := idx>>4, (idx&15)<<2
+		if v := uint32(s.table[word]>>shift) & sketchCounterMask; v < val {
+			val = v
+		}
+	}
+
+	if s.inDoorkeeper(h1, h2) {
+		val++
+	}
+
+	return
+}
+
+func (s *sketch) reset() {
+
+	for i := range s.table {
+		s.table[i] = (s.table[i] >> 1) & sketchResetMask
+	}
+
+	for i := range s.doorkeeper {
+		s.doorkeeper[i] = 0
+	}
+
+	s.additions /= 2
+}
+
+// admitDoorkeeper returns true if the key has already been in the doorkeeper,
+// otherwise it adds the key.
+func (s *sketch) admitDoorkeeper(h1, h2 uint64) (ok bool) {
+
+	ok = true
+	for _, idx := range [...]uint64{h1 & s.doorMask, h2 & s.doorMask} {
+		word, bit := idx>>6, uint64(1)<<(idx&63)
+		if s.doorkeeper[word]&bit == 0 {
+			s.doorkeeper[word] |= bit
+			ok = false
+		}
+	}
+
+	return
+}
+
+func (s *sketch) inDoorkeeper(h1, h2 uint64) bool {
+
+	for _, idx := range [...]uint64{h1 & s.doorMask, h2 & s.doorMask} {
+		if s.doorkeeper[idx>>6]&(uint64(1)<<(idx&63)) == 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// spreadHash returns two independent hashes for the double hashing.
+// The key hash of the numbers is the number itself, so it must be mixed.
+func spreadHash(hash uint64) (h1, h2 uint64) {
+	// finalizer of the splitmix64
+	hash ^= hash >> 30
+	hash *= 0xbf58476d1ce4e5b9
+	hash ^= hash >> 27
+	hash *= 0x94d049bb133111eb
+	hash ^= hash >> 31
+
+	h1 = hash
+	h2 = hash>>32 | hash<<32 | 1
+	return
+}
+
+func nextPowerOfTwo(val uint64) (res uint64) {
+	res = 1
+	for res < val {
+		res <<= 1
+	}
+	return
+}
@@ -0,0 +1,113 @@
+package scache
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Stats is the snapshot of the statistics of the cache.
+type Stats struct {
+	// Hits is the count of the keys which were found, including the cached errors
+	Hits uint64
+	// Misses is the count of the keys which weren't found
+	Misses uint64
+	// Loads is the count of the calls of the loader functions
+	Loads uint64
+	// LoadErrors is the count of the calls of the loader functions which failed
+	LoadErrors uint64
+	// LoadTime is the total duration of the calls of the loader functions
+	LoadTime time.Duration
+	// Expirations is the count of the items which were removed after
+	// the end of their lifetime
+	Expirations uint64
+	// Evictions is the count of the removed items by the reason,
+	// the expired items are counted in Expirations
+	Evictions map[RemovalReason]uint64
+}
+
+// HitRatio returns the part of the keys which were found.
+func (s Stats) HitRatio() float64 {
+	if total := s.Hits + s.Misses; total > 0 {
+		return float64(s.Hits) / float64(total)
+	}
+	return 0
+}
+
+// AverageLoadTime returns the average duration of the call of the loader functions.
+func (s Stats) AverageLoadTime() time.Duration {
+	if s.Loads > 0 {
+		return s.LoadTime / time.Duration(s.Loads)
+	}
+	return 0
+}
+
+// statsCounter is the statistics of the shard, the counters are changed atomically.
+type statsCounter struct {
+	hits        uint64
+	misses      uint64
+	loads       uint64
+	loadErrors  uint64
+	loadTime    int64
+	expirations uint64
+	evictions   [RemovalCleared + 1]uint64
+	// the counters of the shards are in the different cache lines
+	_ [64]byte
+}
+
+// Hit counts the result of the search of the key.
+func (s *statsCounter) Hit(found bool) {
+	if found {
+		atomic.AddUint64(&s.hits, 1)
+	} else {
+		atomic.AddUint64(&s.misses, 1)
+	}
+}
+
+// Loaded counts the call of the loader function.
+func (s *statsCounter) Loaded(delta time.Duration, err error) {
+	atomic.AddUint64(&s.loads, 1)
+	atomic.AddInt64(&s.loadTime, int64(delta))
+	if err != nil {
+		atomic.AddUint64(&s.loadErrors, 1)
+	}
+}
+
+// Removed counts the removed item.
+func (s *statsCounter) Removed(reason RemovalReason) {
+	if reason == RemovalExpired {
+		atomic.AddUint64(&s.expirations, 1)
+	} else if reason > 0 && int(reason) < len(s.evictions) {
+		atomic.AddUint64(&s.evictions[reason], 1)
+	}
+}
+
+// addTo adds the counters to the snapshot.
+func (s *statsCounter) addTo(stats *Stats) {
+
+	stats.Hits += atomic.LoadUint64(&s.hits)
+	stats.Misses += atomic.LoadUint64(&s.misses)
+	stats.Loads += atomic.LoadUint64(&s.loads)
+	stats.LoadErrors += atomic.LoadUint64(&s.loadErrors)
+	stats.LoadTime += time.Duration(atomic.LoadInt64(&s.loadTime))
+	stats.Expirations += atomic.LoadUint64(&s.expirations)
+
+	for reason := range s.evictions {
+		if v := atomic.LoadUint64(&s.evictions[reason]); v > 0 {
+			stats.Evictions[RemovalReason(reason)] += v
+		}
+	}
+}
+
+func (s *statsCounter) reset() {
+
+	atomic.StoreUint64(&s.hits, 0)
+	atomic.StoreUint64(&s.misses, 0)
+	atomic.StoreUint64(&s.loads, 0)
+	atomic.StoreUint64(&s.loadErrors, 0)
+	atomic.StoreInt64(&s.loadTime, 0)
+	atomic.StoreUint64(&s.expirations, 0)
+
+	for reason := range s.evictions {
+		atomic.StoreUint64(&s.evictions[reason], 0)
+	}
+}
@@ -0,0 +1,19 @@
+package scache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStats(t *testing.T) {
+
+	var stats Stats
+	require.Equal(t, float64(0), stats.HitRatio())
+	require.Equal(t, time.Duration(0), stats.AverageLoadTime())
+
+	stats = Stats{Hits: 3, Misses: 1, Loads: 2, LoadTime: time.Second}
+	require.Equal(t, 0.75, stats.HitRatio())
+	require.Equal(t, 500*time.Millisecond, stats.AverageLoadTime())
+}
@@ -0,0 +1,208 @@
+package scache
+
+import (
+	"context"
+	"time"
+)
+
+type TypedLoadFunc[K comparable, V any] func(key K) (value V, err error)
+
+type TypedLoadFuncCtx[K comparable, V any] func(ctx context.Context, key K) (value V, err error)
+
+type TypedLoadFuncWithMeta[K comparable, V any] func(ctx context.Context, key K) (value V, ttl time.Duration, cost int64, err error)
+
+type TypedBulkLoadFunc[K comparable, V any] func(ctx context.Context, keys []K) (values map[K]V, err error)
+
+type TypedWeighFunc[K comparable, V any] func(key K, value V) int64
+
+type TypedEvictFunc[K comparable, V any] func(key K, value V, reason RemovalReason)
+
+// TypedCache is the type-safe wrapper of Cache.
+type TypedCache[K comparable, V any] struct {
+	cache *Cache
+}
+
+type typedBuilder[K comparable, V any] struct {
+	b *builder
+}
+
+// Typed returns the builder of the type-safe cache with the configuration of the builder.
+//
+//	c, err := scache.Typed[string, *User](scache.New(10, 1000).LRU()).LoaderFunc(loadUser).Build()
+func Typed[K comparable, V any](b *builder) *typedBuilder[K, V] {
+	return &typedBuilder[K, V]{
+		b: b,
+	}
+}
+
+func (b *typedBuilder[K, V]) LoaderFunc(val TypedLoadFunc[K, V]) *typedBuilder[K, V] {
+
+	b.b.LoaderFunc(func(key interface{}) (value interface{}, err error) {
+		return val(key.(K))
+	})
+
+	return b
+}
+
+func (b *typedBuilder[K, V]) LoaderFuncCtx(val TypedLoadFuncCtx[K, V]) *typedBuilder[K, V] {
+
+	b.b.LoaderFuncCtx(func(ctx context.Context, key interface{}) (value interface{}, err error) {
+		return val(ctx, key.(K))
+	})
+
+	return b
+}
+
+func (b *typedBuilder[K, V]) LoaderFuncWithMeta(val TypedLoadFuncWithMeta[K, V]) *typedBuilder[K, V] {
+
+	b.b.LoaderFuncWithMeta(func(ctx context.Context, key interface{}) (value interface{}, ttl time.Duration, cost int64, err error) {
+		return val(ctx, key.(K))
+	})
+
+	return b
+}
+
+func (b *typedBuilder[K, V]) BulkLoaderFunc(val TypedBulkLoadFunc[K, V]) *typedBuilder[K, V] {
+
+	b.b.BulkLoaderFunc(func(ctx context.Context, keys []interface{}) (values map[interface{}]interface{}, err error) {
+
+		typedKeys := make([]K, 0, len(keys))
+		for _, key := range keys {
+			typedKeys = append(typedKeys, key.(K))
+		}
+
+		typedValues, err := val(ctx, typedKeys)
+
+		values = make(map[interface{}]interface{}, len(typedValues))
+		for key, value := range typedValues {
+			values[key] = value
+		}
+
+		return
+	})
+
+	return b
+}
+
+func (b *typedBuilder[K, V]) Weigher(val TypedWeighFunc[K, V]) *typedBuilder[K, V] {
+
+	b.b.Weigher(func(key interface{}, value interface{}) int64 {
+		typedValue, _ := value.(V)
+		return val(key.(K), typedValue)
+	})
+
+	return b
+}
+
+func (b *typedBuilder[K, V]) OnEvict(val TypedEvictFunc[K, V]) *typedBuilder[K, V] {
+
+	b.b.OnEvict(func(key interface{}, value interface{}, reason RemovalReason) {
+		typedValue, _ := value.(V)
+		val(key.(K), typedValue, reason)
+	})
+
+	return b
+}
+
+func (b *typedBuilder[K, V]) Build() (*TypedCache[K, V], error) {
+
+	c, err := b.b.Build()
+	if err != nil {
+		return nil, err
+	}
+
+	return &TypedCache[K, V]{cache: c}, nil
+}
+
+// Untyped returns the cache which is wrapped.
+func (c *TypedCache[K, V]) Untyped() *Cache {
+	return c.cache
+}
+
+func (c *TypedCache[K, V]) Close() {
+	c.cache.Close()
+}
+
+func (c *TypedCache[K, V]) Set(key K, value V) {
+	c.cache.Set(key, value)
+}
+
+func (c *TypedCache[K, V]) SetExp(key K, value V, ttl time.Duration) {
+	c.cache.SetExp(key, value, ttl)
+}
+
+func (c *TypedCache[K, V]) SetSliding(key K, value V, ttl time.Duration) {
+	c.cache.SetSliding(key, value, ttl)
+}
+
+func (c *TypedCache[K, V]) SetExpireAt(key K, value V, at time.Time) {
+	c.cache.SetExpireAt(key, value, at)
+}
+
+func (c *TypedCache[K, V]) SetWithCost(key K, value V, cost int64) {
+	c.cache.SetWithCost(key, value, cost)
+}
+
+func (c *TypedCache[K, V]) Get(key K) (value V, err error) {
+	return c.GetCtx(context.Background(), key)
+}
+
+func (c *TypedCache[K, V]) GetCtx(ctx context.Context, key K) (value V, err error) {
+	value, _, err = c.GetWithStale(ctx, key)
+	return
+}
+
+func (c *TypedCache[K, V]) GetWithStale(ctx context.Context, key K) (value V, stale bool, err error) {
+
+	val, stale, err := c.cache.GetWithStale(ctx, key)
+	if err == nil {
+		value, _ = val.(V) // nil interface is stored as the zero value
+	}
+
+	return
+}
+
+func (c *TypedCache[K, V]) GetMulti(keys []K) (values map[K]V, err error) {
+	return c.GetMultiCtx(context.Background(), keys)
+}
+
+func (c *TypedCache[K, V]) GetMultiCtx(ctx context.Context, keys []K) (values map[K]V, err error) {
+
+	untypedKeys := make([]interface{}, 0, len(keys))
+	for _, key := range keys {
+		untypedKeys = append(untypedKeys, key)
+	}
+
+	vals, err := c.cache.GetMultiCtx(ctx, untypedKeys)
+
+	values = make(map[K]V, len(vals))
+	for key, val := range vals {
+		values[key.(K)], _ = val.(V) // nil interface is stored as the zero value
+	}
+
+	return
+}
+
+func (c *TypedCache[K, V]) Del(key K) bool {
+	return c.cache.Del(key)
+}
+
+func (c *TypedCache[K, V]) Clear() {
+	c.cache.Clear()
+}
+
+func (c *TypedCache[K, V]) Stats() Stats {
+	return c.cache.Stats()
+}
+
+func (c *TypedCache[K, V]) ResetStats() {
+	c.cache.ResetStats()
+}
+
+func (c *TypedCache[K, V]) Count() int64 {
+	return c.cache.Count()
+}
+
+func (c *TypedCache[K, V]) Weight() int64 {
+	return c.cache.Weight()
+}
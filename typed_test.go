@@ -0,0 +1,138 @@
+package scache
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTypedCache(t *testing.T) {
+
+	type UserID string
+
+	type User struct {
+		Name string
+	}
+
+	loadFunc := func(key UserID) (val *User, err error) {
+		if key == "1" {
+			val = &User{Name: "one"}
+		} else {
+			err = errors.New("failed to upload")
+		}
+		return
+	}
+
+	weigher := func(key UserID, value *User) int64 {
+		return int64(len(value.Name))
+	}
+
+	cache, err := Typed[UserID, *User](New(2, 100).LRU()).LoaderFunc(loadFunc).Weigher(weigher).Build()
+	require.NoError(t, err)
+	defer cache.Close()
+
+	{
+		val, err := cache.Get("1")
+		require.NoError(t, err)
+		require.Equal(t, &User{Name: "one"}, val)
+	}
+
+	{
+		val, err := cache.Get("2")
+		require.EqualError(t, err, "failed to upload")
+		require.Nil(t, val)
+	}
+
+	cache.Set("3", &User{Name: "three"})
+	{
+		val, err := cache.Get("3")
+		require.NoError(t, err)
+		require.Equal(t, "three", val.Name)
+	}
+
+	require.Equal(t, int64(2), cache.Count())
+	require.Equal(t, int64(8), cache.Weight())
+	require.True(t, cache.Del("3"))
+	require.Equal(t, int64(1), cache.Untyped().Count())
+}
+
+func TestTypedCacheCompositeKeys(t *testing.T) {
+
+	type Key struct {
+		ID   int
+		Name string
+		Tags [2]string
+		Ok   bool
+	}
+
+	structs, err := Typed[Key, int](New(4, 100).LRU()).Build()
+	require.NoError(t, err)
+	defer structs.Close()
+
+	arrays, err := Typed[[2]int, int](New(4, 100).LRU()).Build()
+	require.NoError(t, err)
+	defer arrays.Close()
+
+	pointers, err := Typed[*Key, int](New(4, 100).LRU()).Build()
+	require.NoError(t, err)
+	defer pointers.Close()
+
+	keys := make([]*Key, 0, 10)
+	for i := 0; i < 10; i++ {
+		key := &Key{ID: i, Name: strconv.Itoa(i), Tags: [2]string{"a", "b"}, Ok: i%2 == 0}
+		keys = append(keys, key)
+
+		structs.Set(*key, i)
+		arrays.Set([2]int{i, -i}, i)
+		pointers.Set(key, i)
+	}
+
+	require.Equal(t, int64(10), structs.Count())
+	require.Equal(t, int64(10), arrays.Count())
+	require.Equal(t, int64(10), pointers.Count())
+
+	for i, key := range keys {
+		val, err := structs.Get(Key{ID: i, Name: strconv.Itoa(i), Tags: [2]string{"a", "b"}, Ok: i%2 == 0})
+		require.NoError(t, err)
+		require.Equal(t, i, val)
+
+		val, err = arrays.Get([2]int{i, -i})
+		require.NoError(t, err)
+		require.Equal(t, i, val)
+
+		val, err = pointers.Get(key)
+		require.NoError(t, err)
+		require.Equal(t, i, val)
+	}
+}
+
+func TestTypedCacheBuildError(t *testing.T) {
+
+	cache, err := Typed[string, int](New(1, 1)).Build()
+	require.EqualError(t, err, "invalid kind of cache")
+	require.Nil(t, cache)
+}
+
+func TestTypedCacheGetMulti(t *testing.T) {
+
+	bulkLoadFunc := func(ctx context.Context, keys []int) (values map[int]string, err error) {
+		values = make(map[int]string)
+		for _, key := range keys {
+			if key > 0 {
+				values[key] = strconv.Itoa(key)
+			}
+		}
+		return
+	}
+
+	cache, err := Typed[int, string](New(2, 100).LRU()).BulkLoaderFunc(bulkLoadFunc).Build()
+	require.NoError(t, err)
+	defer cache.Close()
+
+	values, err := cache.GetMulti([]int{-1, 1, 2})
+	require.NoError(t, err)
+	require.Equal(t, map[int]string{1: "1", 2: "2"}, values)
+}
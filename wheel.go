@@ -0,0 +1,217 @@
+package scache
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	wheelBits   = 6
+	wheelSlots  = 1 << wheelBits
+	wheelMask   = wheelSlots - 1
+	wheelLevels = 4
+	// the max count of ticks which the wheel covers, the keys which
+	// expire later are kept in the last slot of the last level
+	wheelRange = 1 << (wheelBits * wheelLevels)
+)
+
+// wheelNode is the key which is scheduled in the timing wheel.
+type wheelNode struct {
+	prev, next *wheelNode
+	key        interface{}
+	tick       uint64
+}
+
+// timingWheel is the hierarchical timing wheel which finds the expired keys.
+// Every level has 64 slots, a slot of the level covers all slots of the previous
+// level, so the keys which expire later are kept in the higher levels and they
+// are moved down to the lower levels when the time approaches. The keys are
+// scheduled and cancelled in O(1).
+type timingWheel struct {
+	mu    sync.Mutex
+	tick  time.Duration
+	start int64  // the time of the first tick
+	now   uint64 // the current tick
+	slots [wheelLevels][wheelSlots]wheelNode
+	nodes map[interface{}]*wheelNode
+}
+
+func newTimingWheel(tick time.Duration, now int64) *timingWheel {
+
+	w := &timingWheel{
+		tick:  tick,
+		start: now,
+		nodes: make(map[interface{}]*wheelNode),
+	}
+
+	for l := range w.slots {
+		for i := range w.slots[l] {
+			root := &w.slots[l][i]
+			root.prev, root.next = root, root
+		}
+	}
+
+	return w
+}
+
+// Schedule adds the key which expires at the time or moves it if the key
+// is scheduled already. The key is cancelled if it doesn't expire.
+func (w *timingWheel) Schedule(key interface{}, expire int64) {
+
+	if expire == 0 {
+		w.Cancel(key)
+		return
+	}
+
+	// the key is found on the tick after its expiration
+	tick := uint64(0)
+	if expire > w.start {
+		tick = uint64((expire-w.start)/int64(w.tick)) + 1
+	}
+
+	w.mu.Lock()
+
+	node, exist := w.nodes[key]
+	if exist {
+		w.unlink(node)
+	} else {
+		node = &wheelNode{key: key}
+		w.nodes[key] = node
+	}
+
+	node.tick = tick
+	w.link(node)
+
+	w.mu.Unlock()
+}
+
+// Cancel removes the key from the wheel.
+func (w *timingWheel) Cancel(key interface{}) {
+
+	w.mu.Lock()
+	if node, exist := w.nodes[key]; exist {
+		w.unlink(node)
+		delete(w.nodes, key)
+	}
+	w.mu.Unlock()
+}
+
+// Clear removes all keys from the wheel.
+func (w *timingWheel) Clear() {
+
+	w.mu.Lock()
+	for l := range w.slots {
+		for i := range w.slots[l] {
+			root := &w.slots[l][i]
+			root.prev, root.next = root, root
+		}
+	}
+	w.nodes = make(map[interface{}]*wheelNode)
+	w.mu.Unlock()
+}
+
+// Len returns the count of the scheduled keys.
+func (w *timingWheel) Len() (val int) {
+	w.mu.Lock()
+	val = len(w.nodes)
+	w.mu.Unlock()
+	return
+}
+
+// Lag returns the time by which the wheel is behind the time.
+func (w *timingWheel) Lag(now int64) (val time.Duration) {
+
+	w.mu.Lock()
+	if passed := now - w.start - int64(w.now)*int64(w.tick); passed > 0 {
+		val = time.Duration(passed)
+	}
+	w.mu.Unlock()
+
+	return
+}
+
+// Advance moves the wheel to the time and appends the keys which expired.
+// The expired keys are removed from the wheel.
+func (w *timingWheel) Advance(now int64, expiredKeys *[]interface{}) {
+
+	if now < w.start {
+		return
+	}
+	target := uint64((now - w.start) / int64(w.tick))
+
+	w.mu.Lock()
+
+	for w.now < target {
+		w.now++
+
+		// the slots of the higher levels are moved down when
+		// the lower levels complete the circle
+		for l := 1; l < wheelLevels; l++ {
+			if w.now&(1<<(wheelBits*l)-1) != 0 {
+				break
+			}
+			w.cascade(l, int(w.now>>(wheelBits*l))&wheelMask)
+		}
+
+		root := &w.slots[0][w.now&wheelMask]
+		for node := root.next; node != root; node = root.next {
+			w.unlink(node)
+			if node.tick > w.now {
+				w.link(node) // it's moved to the last slot of the wheel
+				continue
+			}
+			delete(w.nodes, node.key)
+			*expiredKeys = append(*expiredKeys, node.key)
+		}
+	}
+
+	w.mu.Unlock()
+}
+
+func (w *timingWheel) cascade(level int, slot int) {
+
+	root := &w.slots[level][slot]
+	for node := root.next; node != root; node = root.next {
+		w.unlink(node)
+		if node.tick <= w.now {
+			// the slot of the current tick is processed after the cascade
+			w.push(&w.slots[0][w.now&wheelMask], node)
+		} else {
+			w.link(node)
+		}
+	}
+}
+
+// link adds the node to the slot of its tick, the node which tick has passed
+// is added to the next slot.
+func (w *timingWheel) link(node *wheelNode) {
+
+	tick := node.tick
+	if tick <= w.now {
+		tick = w.now + 1
+	}
+
+	delta := tick - w.now
+	if delta >= wheelRange {
+		tick, delta = w.now+wheelRange-1, wheelRange-1
+	}
+
+	level := 0
+	for delta >= 1<<(wheelBits*(level+1)) {
+		level++
+	}
+
+	w.push(&w.slots[level][int(tick>>(wheelBits*level))&wheelMask], node)
+}
+
+func (w *timingWheel) push(root *wheelNode, node *wheelNode) {
+	node.prev, node.next = root.prev, root
+	root.prev.next = node
+	root.prev = node
+}
+
+func (w *timingWheel) unlink(node *wheelNode) {
+	node.prev.next = node.next
+	node.next.prev = node.prev
+	node.prev, node.next = nil, nil
+}
@@ -0,0 +1,106 @@
+package scache
+
+import (
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTimingWheel(t *testing.T) {
+
+	const tick = time.Millisecond
+
+	w := newTimingWheel(tick, 0)
+
+	// the keys are scheduled to all levels of the wheel and beyond its range
+	ticks := []int64{1, 5, 63, 64, 65, 4095, 4096, 5000, 300000, 1 << 20, wheelRange + 100}
+	for _, v := range ticks {
+		w.Schedule(v, v*int64(tick))
+	}
+	require.Equal(t, len(ticks), w.Len())
+
+	expiredKeys := make([]interface{}, 0)
+	for _, v := range ticks {
+		// the key isn't found before its tick
+		expiredKeys = expiredKeys[:0]
+		w.Advance(v*int64(tick), &expiredKeys)
+		require.Empty(t, expiredKeys, v)
+
+		w.Advance((v+1)*int64(tick), &expiredKeys)
+		require.Equal(t, []interface{}{v}, expiredKeys)
+	}
+	require.Equal(t, 0, w.Len())
+}
+
+func TestTimingWheelCancel(t *testing.T) {
+
+	const tick = time.Millisecond
+
+	w := newTimingWheel(tick, 0)
+
+	w.Schedule("a", int64(10*tick))
+	w.Schedule("b", int64(100*tick))
+	w.Schedule("c", int64(10*tick))
+	w.Schedule("d", int64(10*tick))
+	w.Cancel("c")
+	w.Schedule("d", 0)
+	// the key is moved
+	w.Schedule("b", int64(5*tick))
+	require.Equal(t, 2, w.Len())
+
+	expiredKeys := make([]interface{}, 0)
+	w.Advance(int64(200*tick), &expiredKeys)
+	require.Equal(t, []interface{}{"b", "a"}, expiredKeys)
+
+	// the passed time is found on the next tick
+	expiredKeys = expiredKeys[:0]
+	w.Schedule("e", int64(100*tick))
+	w.Schedule("f", int64(300*tick))
+	w.Advance(int64(201*tick), &expiredKeys)
+	require.Equal(t, []interface{}{"e"}, expiredKeys)
+	require.Equal(t, 1, w.Len())
+}
+
+func TestTimingWheelRandom(t *testing.T) {
+
+	const tick = time.Millisecond
+
+	w := newTimingWheel(tick, 0)
+
+	keys := make([]int, 0, 1000)
+	for i := 1; i <= 1000; i++ {
+		w.Schedule(i, int64(i*i)*int64(tick))
+		keys = append(keys, i)
+	}
+
+	expiredKeys := make([]interface{}, 0)
+	for now := int64(0); now <= 1000*1000; now += 7777 {
+		w.Advance(now*int64(tick), &expiredKeys)
+		for _, k := range expiredKeys {
+			// the key isn't found before its expiration
+			require.Less(t, int64(k.(int)*k.(int)), now)
+		}
+	}
+	w.Advance(int64(1001*1000)*int64(tick), &expiredKeys)
+
+	found := make([]int, 0, len(expiredKeys))
+	for _, k := range expiredKeys {
+		found = append(found, k.(int))
+	}
+	sort.Ints(found)
+	require.Equal(t, keys, found)
+}
+
+func TestTimingWheelLag(t *testing.T) {
+
+	const tick = time.Millisecond
+
+	w := newTimingWheel(tick, 0)
+	require.Equal(t, time.Duration(0), w.Lag(-1))
+	require.Equal(t, 10*tick, w.Lag(int64(10*tick)))
+
+	w.Advance(int64(10*tick), &[]interface{}{})
+	require.Equal(t, time.Duration(0), w.Lag(int64(10*tick)))
+}